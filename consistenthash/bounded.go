@@ -0,0 +1,62 @@
+/*
+Copyright 2013 Google Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package consistenthash
+
+import "math"
+
+// 创建带有负载上限的哈希环数据结构，参考Google的Consistent Hashing with Bounded Loads
+// GetBounded分配的节点负载不会超过平均负载的(1+epsilon)倍
+// epsilon越小负载越均衡，但是key的分配越不稳定，New创建的哈希环epsilon为0
+func NewBounded(replicas int, epsilon float64, fn Hash) *Map {
+	m := New(replicas, fn)
+	m.epsilon = epsilon
+	return m
+}
+
+// 获取key对应的服务节点，跳过负载已经达到上限的节点
+// load是每个节点当前的负载，上限是分配这个key之后平均负载的(1+epsilon)倍向上取整
+// 调用者分配key之后需要自己增加对应节点的负载
+func (m *Map) GetBounded(key string, load map[string]int64) string {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	if len(m.keys) == 0 {
+		return ""
+	}
+
+	var total int64
+	for node := range m.nodes {
+		total += load[node]
+	}
+	limit := m.loadLimit(total + 1)
+
+	hash := m.hash([]byte(key))
+	idx := searchHash(m.keys, hash)
+	for i := 0; i < len(m.keys); i++ {
+		node := m.hashMap[m.keys[(idx+i)%len(m.keys)]]
+		if load[node] < limit {
+			return node
+		}
+	}
+	// 上限至少是平均负载，总会有节点低于上限
+	return ""
+}
+
+// 计算总负载为total时每个节点的负载上限，调用时需要持有读锁
+func (m *Map) loadLimit(total int64) int64 {
+	avg := float64(total) / float64(len(m.nodes))
+	return int64(math.Ceil(avg * (1 + m.epsilon)))
+}
@@ -0,0 +1,71 @@
+/*
+Copyright 2013 Google Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package consistenthash
+
+import (
+	"fmt"
+	"testing"
+)
+
+// 集中在少数节点的key也不会让任何节点超过负载上限
+func TestGetBounded(t *testing.T) {
+	const epsilon = 0.25
+	hash := NewBounded(50, epsilon, nil)
+	hash.Add("Bill", "Bob", "Bonny", "Becky")
+
+	load := make(map[string]int64)
+	var total int64
+	for i := 0; i < 1000; i++ {
+		// 大部分key重复，普通的一致性哈希会把它们都分配到同一个节点
+		key := fmt.Sprint("key", i%3)
+		node := hash.GetBounded(key, load)
+		if node == "" {
+			t.Fatalf("GetBounded(%s) returned no node", key)
+		}
+		load[node]++
+		total++
+
+		hash.mu.RLock()
+		limit := hash.loadLimit(total)
+		hash.mu.RUnlock()
+		if load[node] > limit {
+			t.Fatalf("node %s has load %d; want at most %d", node, load[node], limit)
+		}
+	}
+	if len(load) != 4 {
+		t.Errorf("keys assigned to %d nodes; want 4", len(load))
+	}
+
+	// 负载较低时和Get相同
+	if got, want := hash.GetBounded("Ben", nil), hash.Get("Ben"); got != want {
+		t.Errorf("GetBounded with no load = %s; want %s", got, want)
+	}
+}
+
+// 没有虚拟节点的节点不会被添加，不影响平均负载
+func TestGetBoundedZeroReplicas(t *testing.T) {
+	hash := NewBounded(50, 0.25, nil)
+	hash.Add("A")
+	hash.AddReplicas("Z", 0)
+	if hash.Has("Z") {
+		t.Fatal("Has reported a node added with zero replicas")
+	}
+	load := map[string]int64{"A": 1}
+	if got := hash.GetBounded("key", load); got != "A" {
+		t.Fatalf("GetBounded = %q; want A", got)
+	}
+}
@@ -19,18 +19,51 @@ package consistenthash
 
 import (
 	"hash/crc32"
+	"math"
 	"sort"
 	"strconv"
+	"sync"
 )
 
 type Hash func(data []byte) uint32
 
-// 哈希环数据结构
+// 哈希环数据结构，是并发安全的，可以在查找节点的同时增加和移除节点
 type Map struct {
-	hash     Hash           // 哈希算法
-	replicas int            // 为了让服务节点更加分散
-	keys     []int          // 哈希值列表
-	hashMap  map[int]string // 哈希值对应的服务节点
+	// 生成虚拟节点哈希值的字符串，为空时使用DefaultReplicaKey
+	// 需要在添加节点之前设置，同一个集群中的哈希环需要使用相同的格式
+	ReplicaKey func(index int, node string) string
+
+	mu       sync.RWMutex
+	hash     Hash              // 哈希算法
+	replicas int               // 为了让服务节点更加分散
+	keys     []uint32          // 哈希值列表
+	hashMap  map[uint32]string // 哈希值对应的服务节点
+	nodes    map[string]int    // 服务节点的虚拟节点数量
+	epsilon  float64           // GetBounded允许节点超出平均负载的比例
+
+	// 哈希值冲突的虚拟节点，哈希值对应所有冲突的服务节点
+	// hashMap中保存名称最小的节点，冲突的结果和节点的添加顺序无关
+	collisions map[uint32][]string
+}
+
+// 默认的虚拟节点格式，节点名称和序号之间用#分隔
+func DefaultReplicaKey(index int, node string) string {
+	return node + "#" + strconv.Itoa(index)
+}
+
+// 旧的虚拟节点格式，序号直接放在节点名称前面
+// 这种格式有歧义，例如节点"0"的第1个虚拟节点和节点""的第10个虚拟节点都是"10"，
+// 哈希值相同导致冲突，分布不均匀，只在需要和旧版本保持相同的分配结果时使用
+func LegacyReplicaKey(index int, node string) string {
+	return strconv.Itoa(index) + node
+}
+
+// 计算节点第index个虚拟节点的哈希值
+func (m *Map) replicaHash(index int, node string) uint32 {
+	if m.ReplicaKey != nil {
+		return m.hash([]byte(m.ReplicaKey(index, node)))
+	}
+	return m.hash([]byte(DefaultReplicaKey(index, node)))
 }
 
 // 创建哈希环数据结构
@@ -38,7 +71,10 @@ func New(replicas int, fn Hash) *Map {
 	m := &Map{
 		replicas: replicas,
 		hash:     fn,
-		hashMap:  make(map[int]string),
+		hashMap:  make(map[uint32]string),
+		nodes:    make(map[string]int),
+
+		collisions: make(map[uint32][]string),
 	}
 	// 默认使用的哈希算法：crc32.ChecksumIEEE
 	if m.hash == nil {
@@ -49,31 +85,203 @@ func New(replicas int, fn Hash) *Map {
 
 // 判断节点个数是否为0
 func (m *Map) IsEmpty() bool {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
 	return len(m.keys) == 0
 }
 
-// 增加节点到哈希环
+// 增加节点到哈希环，已经存在的节点保持不变，不会重复添加虚拟节点
+// 需要修改节点的虚拟节点数量时，先移除节点再重新添加
 func (m *Map) Add(keys ...string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
 	for _, key := range keys {
-		for i := 0; i < m.replicas; i++ {
-			// 节点的字符串添加replica，为了哈希值的分散
-			hash := int(m.hash([]byte(strconv.Itoa(i) + key)))
+		m.add(key, m.replicas)
+	}
+	// 将哈希值列表升序便于搜索
+	sortHashes(m.keys)
+}
+
+// 增加一个节点到哈希环，返回节点是否是新添加的，节点已经存在时返回false
+func (m *Map) AddNode(key string) (added bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if !m.add(key, m.replicas) {
+		return false
+	}
+	sortHashes(m.keys)
+	return true
+}
+
+// 增加带有权重的节点到哈希环，节点的虚拟节点数量是weight倍的replicas
+// 权重越大的节点在哈希环上占据的范围越大，分配到的key越多，节点已经存在时保持不变
+// weight小于等于0时不添加节点，没有虚拟节点的节点不会分配到任何key
+func (m *Map) AddWeighted(key string, weight int) {
+	if weight <= 0 {
+		return
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.add(key, weight*m.replicas)
+	sortHashes(m.keys)
+}
+
+// 增加节点到哈希环，虚拟节点数量是replicas，而不是哈希环的replicas，节点已经存在时保持不变
+// replicas小于等于0时不添加节点
+func (m *Map) AddReplicas(key string, replicas int) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.add(key, replicas)
+	sortHashes(m.keys)
+}
+
+// 增加n个虚拟节点到哈希环，不排序哈希值列表，调用时需要持有写锁
+// 节点已经存在或者n小于等于0时返回false，没有虚拟节点的节点不能被记录为成员
+func (m *Map) add(key string, n int) bool {
+	if n <= 0 {
+		return false
+	}
+	if _, ok := m.nodes[key]; ok {
+		return false
+	}
+	for i := 0; i < n; i++ {
+		// 节点的字符串添加replica，为了哈希值的分散
+		hash := m.replicaHash(i, key)
+		owner, ok := m.hashMap[hash]
+		if !ok {
 			m.keys = append(m.keys, hash)
 			m.hashMap[hash] = key
+			continue
+		}
+		if owner == key {
+			continue
 		}
+
+		// 哈希值已经属于其他节点，不重复添加哈希值，记录所有冲突的节点
+		nodes := m.collisions[hash]
+		if nodes == nil {
+			nodes = []string{owner}
+		}
+		if !contains(nodes, key) {
+			nodes = append(nodes, key)
+		}
+		m.collisions[hash] = nodes
+		m.hashMap[hash] = minNode(nodes)
 	}
-	// 将哈希值列表升序便于搜索
-	sort.Ints(m.keys)
+	m.nodes[key] = n
+	return true
+}
+
+// 从冲突的哈希值中移除节点，返回哈希值是否还属于其他节点，调用时需要持有写锁
+func (m *Map) removeCollision(hash uint32, key string) bool {
+	nodes, ok := m.collisions[hash]
+	if !ok {
+		return false
+	}
+	for i, node := range nodes {
+		if node == key {
+			nodes = append(nodes[:i], nodes[i+1:]...)
+			break
+		}
+	}
+	if len(nodes) == 1 {
+		delete(m.collisions, hash)
+	} else {
+		m.collisions[hash] = nodes
+	}
+	m.hashMap[hash] = minNode(nodes)
+	return true
+}
+
+// 将哈希值列表升序排列，哈希值是无符号数，在所有平台上顺序都相同
+func sortHashes(hashes []uint32) {
+	sort.Slice(hashes, func(i, j int) bool { return hashes[i] < hashes[j] })
+}
+
+// 在升序的哈希值列表中找到大于等于hash的第1个值的位置
+func searchHash(hashes []uint32, hash uint32) int {
+	return sort.Search(len(hashes), func(i int) bool { return hashes[i] >= hash })
+}
+
+// 判断节点列表是否包含节点
+func contains(nodes []string, key string) bool {
+	for _, node := range nodes {
+		if node == key {
+			return true
+		}
+	}
+	return false
+}
+
+// 获取名称最小的节点
+func minNode(nodes []string) string {
+	owner := nodes[0]
+	for _, node := range nodes[1:] {
+		owner = min(owner, node)
+	}
+	return owner
+}
+
+// 从哈希环移除节点，节点不存在时什么也不做
+// 先收集所有需要删除的哈希值，再从有序的哈希值列表中一次删除，不需要重新排序
+func (m *Map) Remove(keys ...string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var removed []uint32
+	for _, key := range keys {
+		n, ok := m.nodes[key]
+		if !ok {
+			continue
+		}
+		delete(m.nodes, key)
+		for i := 0; i < n; i++ {
+			hash := m.replicaHash(i, key)
+			// 哈希值冲突时可能属于其他节点
+			if m.removeCollision(hash, key) {
+				continue
+			}
+			if m.hashMap[hash] == key {
+				delete(m.hashMap, hash)
+				removed = append(removed, hash)
+			}
+		}
+	}
+	if len(removed) == 0 {
+		return
+	}
+	sortHashes(removed)
+
+	// 从第一个删除的哈希值开始，把保留的哈希值向前移动
+	j := searchHash(m.keys, removed[0])
+	for _, hash := range m.keys[j:] {
+		if len(removed) > 0 && hash == removed[0] {
+			removed = removed[1:]
+			continue
+		}
+		m.keys[j] = hash
+		j++
+	}
+	m.keys = m.keys[:j]
+}
+
+// 判断服务节点是否在哈希环中
+func (m *Map) Has(key string) bool {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	_, ok := m.nodes[key]
+	return ok
 }
 
 // 获取key哈希值对应的服务节点
 func (m *Map) Get(key string) string {
-	if m.IsEmpty() {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	if len(m.keys) == 0 {
 		return ""
 	}
 
 	// 哈希列表中找到比key的哈希值大的第1个值
-	hash := int(m.hash([]byte(key)))
+	hash := m.hash([]byte(key))
 	idx := sort.Search(len(m.keys), func(i int) bool { return m.keys[i] >= hash })
 	if idx == len(m.keys) {
 		idx = 0
@@ -81,3 +289,75 @@ func (m *Map) Get(key string) string {
 
 	return m.hashMap[m.keys[idx]]
 }
+
+// 获取key对应的服务节点和顺时针方向的下一个不同节点，只有一个节点时backup为空
+// 和GetN(key, 2)相同，但是和Get一样只在计算哈希值时分配内存
+func (m *Map) GetTwo(key string) (primary, backup string) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	if len(m.keys) == 0 {
+		return "", ""
+	}
+
+	hash := m.hash([]byte(key))
+	idx := searchHash(m.keys, hash)
+	primary = m.hashMap[m.keys[idx%len(m.keys)]]
+	for i := 1; i < len(m.keys); i++ {
+		if node := m.hashMap[m.keys[(idx+i)%len(m.keys)]]; node != primary {
+			return primary, node
+		}
+	}
+	return primary, ""
+}
+
+// 获取key对应的所有服务节点，按照从key的哈希值开始顺时针的顺序，每个节点只出现一次
+// 第一个节点和Get相同，访问失败时可以依次尝试后面的节点
+func (m *Map) PreferenceList(key string) []string {
+	return m.GetN(key, math.MaxInt)
+}
+
+// 获取key对应的n个不同的服务节点，用于多副本存储，返回的顺序就是副本的优先顺序
+// 结果是PreferenceList的前n个节点，节点数量不足n时返回所有节点
+func (m *Map) GetN(key string, n int) []string {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	if len(m.keys) == 0 || n <= 0 {
+		return nil
+	}
+	if n > len(m.nodes) {
+		n = len(m.nodes)
+	}
+
+	hash := m.hash([]byte(key))
+	idx := searchHash(m.keys, hash)
+
+	nodes := make([]string, 0, n)
+	seen := make(map[string]bool, n)
+	for i := 0; i < len(m.keys) && len(nodes) < n; i++ {
+		node := m.hashMap[m.keys[(idx+i)%len(m.keys)]]
+		if !seen[node] {
+			seen[node] = true
+			nodes = append(nodes, node)
+		}
+	}
+	return nodes
+}
+
+// 获取哈希环中所有的服务节点，按照名称升序
+func (m *Map) Members() []string {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	members := make([]string, 0, len(m.nodes))
+	for node := range m.nodes {
+		members = append(members, node)
+	}
+	sort.Strings(members)
+	return members
+}
+
+// 获取哈希环中服务节点的数量
+func (m *Map) Count() int {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return len(m.nodes)
+}
@@ -18,7 +18,9 @@ package consistenthash
 
 import (
 	"fmt"
+	"sort"
 	"strconv"
+	"sync"
 	"testing"
 )
 
@@ -32,6 +34,8 @@ func TestHashing(t *testing.T) {
 		}
 		return uint32(i)
 	})
+	// 使用旧的虚拟节点格式，序号放在节点名称前面
+	hash.ReplicaKey = LegacyReplicaKey
 
 	// 添加节点[6,4,2]，在哈希环生成[2, 4, 6, 12, 14, 16, 22, 24, 26]
 	hash.Add("6", "4", "2")
@@ -66,6 +70,9 @@ func TestHashing(t *testing.T) {
 func TestConsistency(t *testing.T) {
 	hash1 := New(1, nil)
 	hash2 := New(1, nil)
+	// 测试的结果依赖这些节点在旧的虚拟节点格式下的位置
+	hash1.ReplicaKey = LegacyReplicaKey
+	hash2.ReplicaKey = LegacyReplicaKey
 
 	// 测试相同节点在2个哈希环的输出结果
 	hash1.Add("Bill", "Bob", "Bonny")
@@ -86,6 +93,358 @@ func TestConsistency(t *testing.T) {
 
 }
 
+// 虚拟节点的哈希值冲突时，哈希环仍然保持一致
+func TestCollision(t *testing.T) {
+	// 节点A和B的虚拟节点哈希值都是10，C的是20，其他字符串转换为整型
+	collide := func(key []byte) uint32 {
+		switch string(key) {
+		case "A#0", "B#0":
+			return 10
+		case "C#0":
+			return 20
+		}
+		i, err := strconv.Atoi(string(key))
+		if err != nil {
+			panic(err)
+		}
+		return uint32(i)
+	}
+	hash1 := New(1, collide)
+	hash1.Add("B", "C", "A")
+	hash2 := New(1, collide)
+	hash2.Add("A", "B", "C")
+
+	for _, hash := range []*Map{hash1, hash2} {
+		if got, want := fmt.Sprint(hash.keys), "[10 20]"; got != want {
+			t.Fatalf("got hashes %s; want %s", got, want)
+		}
+		// 冲突的哈希值属于名称最小的节点，和添加顺序无关
+		if got := hash.Get("5"); got != "A" {
+			t.Errorf("Get(5) = %s; want A", got)
+		}
+		if got := hash.Get("15"); got != "C" {
+			t.Errorf("Get(15) = %s; want C", got)
+		}
+	}
+
+	hash1.Remove("A")
+	if got, want := fmt.Sprint(hash1.keys), "[10 20]"; got != want {
+		t.Fatalf("got hashes %s after removing A; want %s", got, want)
+	}
+	if got := hash1.Get("5"); got != "B" {
+		t.Errorf("Get(5) after removing A = %s; want B", got)
+	}
+	hash1.Remove("B")
+	if got, want := fmt.Sprint(hash1.keys), "[20]"; got != want {
+		t.Fatalf("got hashes %s after removing B; want %s", got, want)
+	}
+	if got := hash1.Get("5"); got != "C" {
+		t.Errorf("Get(5) after removing B = %s; want C", got)
+	}
+}
+
+// 最高位为1的哈希值按照无符号数排序，在32位平台上不会变成负数
+func TestHighBitHashes(t *testing.T) {
+	hashes := map[string]uint32{
+		"A#0": 0xFFFFFFF0,
+		"B#0": 0x10,
+		"C#0": 0x80000000,
+		"max": 0xFFFFFFFF,
+		"hi":  0xFFFFFFF5,
+		"top": 0x80000001,
+		"mid": 0x7FFFFFFF,
+	}
+	hash := New(1, func(key []byte) uint32 { return hashes[string(key)] })
+	hash.Add("A", "B", "C")
+
+	if got, want := fmt.Sprintf("%x", hash.keys), "[10 80000000 fffffff0]"; got != want {
+		t.Fatalf("got hashes %s; want %s", got, want)
+	}
+	testCases := map[string]string{
+		"mid": "C",
+		"top": "A",
+		// 超出最大的哈希值之后回到环的开头
+		"hi":  "B",
+		"max": "B",
+	}
+	for k, v := range testCases {
+		if got := hash.Get(k); got != v {
+			t.Errorf("Get(%s) = %s; want %s", k, got, v)
+		}
+	}
+}
+
+// 重复添加节点不会增加虚拟节点
+func TestHas(t *testing.T) {
+	hash := New(3, nil)
+	if hash.Has("Bill") {
+		t.Error("Has(Bill) = true on an empty ring")
+	}
+
+	hash.Add("Bill", "Bob")
+	hash.Add("Bill")
+	hash.AddWeighted("Bob", 2)
+	hash.AddReplicas("Bill", 10)
+	if !hash.Has("Bill") || !hash.Has("Bob") {
+		t.Error("Has = false for an added node")
+	}
+	if got := hash.Count(); got != 2 {
+		t.Errorf("Count = %d; want 2", got)
+	}
+	if got := len(hash.keys); got != 6 {
+		t.Errorf("got %d hashes; want 6", got)
+	}
+
+	hash.Remove("Bill")
+	if hash.Has("Bill") {
+		t.Error("Has(Bill) = true after Remove")
+	}
+	if got := len(hash.keys); got != 3 {
+		t.Errorf("got %d hashes after Remove; want 3", got)
+	}
+}
+
+func TestAddNode(t *testing.T) {
+	hash := New(3, nil)
+	if !hash.AddNode("Bill") {
+		t.Error("AddNode(Bill) = false for a new node")
+	}
+	if hash.AddNode("Bill") {
+		t.Error("AddNode(Bill) = true for an existing node")
+	}
+	hash.Add("Bob")
+	if hash.AddNode("Bob") {
+		t.Error("AddNode(Bob) = true for a node added by Add")
+	}
+	if got := len(hash.keys); got != 6 {
+		t.Errorf("got %d hashes; want 6", got)
+	}
+
+	hash.Remove("Bill")
+	if !hash.AddNode("Bill") {
+		t.Error("AddNode(Bill) = false after Remove")
+	}
+}
+
+// 默认的虚拟节点格式没有歧义，不同节点的虚拟节点不会生成相同的字符串
+func TestReplicaKey(t *testing.T) {
+	if LegacyReplicaKey(1, "0") != LegacyReplicaKey(10, "") {
+		t.Fatal("legacy replica keys should collide")
+	}
+	if DefaultReplicaKey(1, "0") == DefaultReplicaKey(10, "") {
+		t.Errorf("default replica keys collide: %q", DefaultReplicaKey(1, "0"))
+	}
+
+	legacy := New(11, nil)
+	legacy.ReplicaKey = LegacyReplicaKey
+	legacy.Add("0", "")
+	if got := len(legacy.keys); got != 21 {
+		t.Errorf("got %d hashes with the legacy format; want 21", got)
+	}
+
+	hash := New(11, nil)
+	hash.Add("0", "")
+	if got := len(hash.keys); got != 22 {
+		t.Errorf("got %d hashes with the default format; want 22", got)
+	}
+}
+
+func TestRemove(t *testing.T) {
+	hash := New(50, nil)
+	hash.Add("Bill", "Bob", "Bonny")
+
+	// 移除不存在的节点什么也不做
+	hash.Remove("Ben")
+	if got := len(hash.keys); got != 150 {
+		t.Fatalf("got %d hashes; want 150", got)
+	}
+
+	hash.Remove("Bob")
+	if got := len(hash.keys); got != 100 {
+		t.Fatalf("got %d hashes after Remove; want 100", got)
+	}
+	for i := 0; i < 1000; i++ {
+		if node := hash.Get(strconv.Itoa(i)); node == "Bob" {
+			t.Fatalf("Get(%d) returned the removed node", i)
+		}
+	}
+
+	hash.Remove("Bill", "Bonny")
+	if !hash.IsEmpty() {
+		t.Fatalf("got %d hashes after removing every node; want 0", len(hash.keys))
+	}
+	if node := hash.Get("Ben"); node != "" {
+		t.Fatalf("Get on an empty ring = %q; want empty", node)
+	}
+}
+
+// 每个节点分配到的key的比例接近它的权重
+func TestAddWeighted(t *testing.T) {
+	hash := New(200, nil)
+	weights := map[string]int{"small": 1, "medium": 2, "large": 3}
+	for node, weight := range weights {
+		hash.AddWeighted(node, weight)
+	}
+	if got := len(hash.keys); got != 1200 {
+		t.Fatalf("got %d hashes; want 1200", got)
+	}
+
+	const n = 60000
+	counts := make(map[string]int)
+	for i := 0; i < n; i++ {
+		counts[hash.Get(fmt.Sprint("key", i))]++
+	}
+	for node, weight := range weights {
+		want := float64(n) * float64(weight) / 6
+		if got := float64(counts[node]); got < want*0.8 || got > want*1.2 {
+			t.Errorf("node %s got %v keys; want about %v", node, got, want)
+		}
+	}
+
+	// 移除带有权重的节点时移除所有虚拟节点
+	hash.Remove("large")
+	if got := len(hash.keys); got != 600 {
+		t.Fatalf("got %d hashes after Remove; want 600", got)
+	}
+}
+
+// 权重小于等于0的节点不会被添加
+func TestAddWeightedNonPositive(t *testing.T) {
+	hash := New(3, nil)
+	hash.Add("Bill")
+	hash.AddWeighted("zero", 0)
+	hash.AddWeighted("negative", -1)
+	if hash.Has("zero") || hash.Has("negative") {
+		t.Fatal("Has reported a node added with a non-positive weight")
+	}
+	if got := hash.Count(); got != 1 {
+		t.Fatalf("Count = %d; want 1", got)
+	}
+	if got := hash.GetN("key", 2); len(got) != 1 {
+		t.Fatalf("GetN = %v; want only Bill", got)
+	}
+}
+
+// 虚拟节点数量加倍的节点分配到的key接近其他节点的两倍
+func TestAddReplicas(t *testing.T) {
+	hash := New(100, nil)
+	hash.Add("Bill", "Bob")
+	hash.AddReplicas("Bonny", 200)
+	if got := len(hash.keys); got != 400 {
+		t.Fatalf("got %d hashes; want 400", got)
+	}
+
+	const n = 40000
+	counts := make(map[string]int)
+	for i := 0; i < n; i++ {
+		counts[hash.Get(fmt.Sprint("key", i))]++
+	}
+	if got, want := float64(counts["Bonny"]), float64(n)/2; got < want*0.8 || got > want*1.2 {
+		t.Errorf("node Bonny got %v keys; want about %v", got, want)
+	}
+
+	hash.Remove("Bonny")
+	if got := len(hash.keys); got != 200 {
+		t.Fatalf("got %d hashes after Remove; want 200", got)
+	}
+}
+
+func TestGetN(t *testing.T) {
+	hash := New(3, func(key []byte) uint32 {
+		i, err := strconv.Atoi(string(key))
+		if err != nil {
+			panic(err)
+		}
+		return uint32(i)
+	})
+	hash.ReplicaKey = LegacyReplicaKey
+
+	// 在哈希环生成[2, 4, 6, 12, 14, 16, 22, 24, 26]
+	hash.Add("6", "4", "2")
+
+	testCases := []struct {
+		key  string
+		n    int
+		want string
+	}{
+		{"3", 2, "[4 6]"},
+		{"11", 3, "[2 4 6]"},
+		// 超出最大的哈希值之后回到环的开头
+		{"25", 2, "[6 2]"},
+		{"27", 3, "[2 4 6]"},
+		// 节点数量不足n时返回所有节点
+		{"15", 5, "[6 2 4]"},
+		{"15", 0, "[]"},
+	}
+	for _, tc := range testCases {
+		if got := fmt.Sprint(hash.GetN(tc.key, tc.n)); got != tc.want {
+			t.Errorf("GetN(%s, %d) = %s; want %s", tc.key, tc.n, got, tc.want)
+		}
+	}
+
+	// 第一个节点和Get相同
+	for _, key := range []string{"3", "11", "25", "27"} {
+		if got := hash.GetN(key, 1); len(got) != 1 || got[0] != hash.Get(key) {
+			t.Errorf("GetN(%s, 1) = %v; want [%s]", key, got, hash.Get(key))
+		}
+	}
+}
+
+// 查找节点的同时增加和移除节点，需要配合-race运行
+func TestConcurrent(t *testing.T) {
+	hash := New(50, nil)
+	hash.Add("Bill", "Bob", "Bonny")
+
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			for j := 0; j < 1000; j++ {
+				key := fmt.Sprint("key", i, j)
+				if node := hash.Get(key); node == "" {
+					t.Errorf("Get(%s) returned no node", key)
+					return
+				}
+				hash.GetN(key, 2)
+			}
+		}(i)
+	}
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for j := 0; j < 100; j++ {
+			node := fmt.Sprint("node", j)
+			hash.AddWeighted(node, 2)
+			hash.Remove(node)
+		}
+	}()
+	wg.Wait()
+
+	if got := len(hash.keys); got != 150 {
+		t.Errorf("got %d hashes; want 150", got)
+	}
+}
+
+func TestMembers(t *testing.T) {
+	hash := New(3, nil)
+	if got := fmt.Sprint(hash.Members()); got != "[]" || hash.Count() != 0 {
+		t.Errorf("empty ring members = %s, count %d; want [], 0", got, hash.Count())
+	}
+
+	hash.Add("Bob", "Bill")
+	hash.AddWeighted("Bonny", 2)
+	hash.Remove("Bob", "Ben")
+	hash.Add("Becky", "Bill")
+
+	if got, want := fmt.Sprint(hash.Members()), "[Becky Bill Bonny]"; got != want {
+		t.Errorf("Members = %s; want %s", got, want)
+	}
+	if got := hash.Count(); got != 3 {
+		t.Errorf("Count = %d; want 3", got)
+	}
+}
+
 func BenchmarkGet8(b *testing.B)   { benchmarkGet(b, 8) }
 func BenchmarkGet32(b *testing.B)  { benchmarkGet(b, 32) }
 func BenchmarkGet128(b *testing.B) { benchmarkGet(b, 128) }
@@ -109,3 +468,120 @@ func benchmarkGet(b *testing.B, shards int) {
 		hash.Get(buckets[i&(shards-1)])
 	}
 }
+
+func TestPreferenceList(t *testing.T) {
+	hash := New(50, nil)
+	nodes := []string{"Bill", "Bob", "Bonny", "Becky", "Ben"}
+	hash.Add(nodes...)
+
+	for i := 0; i < 100; i++ {
+		key := fmt.Sprint("key", i)
+		list := hash.PreferenceList(key)
+		if len(list) != len(nodes) {
+			t.Fatalf("PreferenceList(%s) = %v; want %d nodes", key, list, len(nodes))
+		}
+		seen := make(map[string]bool)
+		for _, node := range list {
+			if seen[node] {
+				t.Fatalf("PreferenceList(%s) = %v; %s appears twice", key, list, node)
+			}
+			seen[node] = true
+		}
+		if list[0] != hash.Get(key) {
+			t.Errorf("PreferenceList(%s)[0] = %s; want %s", key, list[0], hash.Get(key))
+		}
+		// 结果是确定的，GetN是它的前n个节点
+		if got, want := fmt.Sprint(hash.PreferenceList(key)), fmt.Sprint(list); got != want {
+			t.Errorf("PreferenceList(%s) = %s then %s", key, want, got)
+		}
+		if got, want := fmt.Sprint(hash.GetN(key, 2)), fmt.Sprint(list[:2]); got != want {
+			t.Errorf("GetN(%s, 2) = %s; want %s", key, got, want)
+		}
+	}
+
+	if got := New(3, nil).PreferenceList("key"); got != nil {
+		t.Errorf("PreferenceList on an empty ring = %v; want nil", got)
+	}
+}
+
+func TestGetTwo(t *testing.T) {
+	hash := New(3, func(key []byte) uint32 {
+		i, err := strconv.Atoi(string(key))
+		if err != nil {
+			panic(err)
+		}
+		return uint32(i)
+	})
+	hash.ReplicaKey = LegacyReplicaKey
+	if primary, backup := hash.GetTwo("1"); primary != "" || backup != "" {
+		t.Errorf("GetTwo on an empty ring = %q, %q; want empty", primary, backup)
+	}
+
+	// 只有一个节点时没有备份节点
+	hash.Add("2")
+	if primary, backup := hash.GetTwo("15"); primary != "2" || backup != "" {
+		t.Errorf("GetTwo(15) = %q, %q; want 2, empty", primary, backup)
+	}
+
+	// 在哈希环生成[2, 4, 12, 14, 22, 24]
+	hash.Add("4")
+	testCases := map[string][2]string{
+		"1":  {"2", "4"},
+		"3":  {"4", "2"},
+		"14": {"4", "2"},
+		// 超出最大的哈希值之后回到环的开头
+		"23": {"4", "2"},
+		"25": {"2", "4"},
+	}
+	for key, want := range testCases {
+		if primary, backup := hash.GetTwo(key); primary != want[0] || backup != want[1] {
+			t.Errorf("GetTwo(%s) = %s, %s; want %s, %s", key, primary, backup, want[0], want[1])
+		}
+	}
+
+	// 和Get相同，只有把key转换为[]byte时分配内存
+	if allocs := testing.AllocsPerRun(100, func() { hash.GetTwo("25") }); allocs > 1 {
+		t.Errorf("GetTwo allocated %v times; want at most 1", allocs)
+	}
+}
+
+// 在有10万个虚拟节点的哈希环上移除10个节点
+func BenchmarkRemove(b *testing.B) {
+	benchmarkRemove(b, func(m *Map, nodes []string) { m.Remove(nodes...) })
+}
+
+// 过滤之后重新排序哈希值列表，作为Remove的对比
+func BenchmarkRemoveRebuild(b *testing.B) {
+	benchmarkRemove(b, func(m *Map, nodes []string) {
+		for _, node := range nodes {
+			for i := 0; i < m.nodes[node]; i++ {
+				delete(m.hashMap, m.replicaHash(i, node))
+			}
+			delete(m.nodes, node)
+		}
+		keys := make([]uint32, 0, len(m.hashMap))
+		for hash := range m.hashMap {
+			keys = append(keys, hash)
+		}
+		sort.Slice(keys, func(i, j int) bool { return keys[i] < keys[j] })
+		m.keys = keys
+	})
+}
+
+func benchmarkRemove(b *testing.B, remove func(m *Map, nodes []string)) {
+	hash := New(100, nil)
+	var nodes []string
+	for i := 0; i < 1000; i++ {
+		nodes = append(nodes, fmt.Sprintf("shard-%d", i))
+	}
+	hash.Add(nodes...)
+	removed := nodes[:10]
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		remove(hash, removed)
+		b.StopTimer()
+		hash.Add(removed...)
+		b.StartTimer()
+	}
+}
@@ -0,0 +1,68 @@
+/*
+Copyright 2013 Google Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package consistenthash
+
+import "math"
+
+// 节点负载的统计数据
+type LoadStats struct {
+	Mean   float64 // 每个节点的平均key数量
+	StdDev float64 // key数量的标准差
+	Min    int     // key最少的节点的数量
+	Max    int     // key最多的节点的数量
+}
+
+// 统计keys分配到每个节点的数量，没有分配到key的节点数量为0，不会修改哈希环
+func (m *Map) Distribution(keys []string) map[string]int {
+	m.mu.RLock()
+	counts := make(map[string]int, len(m.nodes))
+	for node := range m.nodes {
+		counts[node] = 0
+	}
+	m.mu.RUnlock()
+
+	for _, key := range keys {
+		if node := m.Get(key); node != "" {
+			counts[node]++
+		}
+	}
+	return counts
+}
+
+// 统计keys分配到每个节点的数量的平均值、标准差、最小值和最大值，用来衡量负载是否均衡
+func (m *Map) DistributionStats(keys []string) LoadStats {
+	counts := m.Distribution(keys)
+	if len(counts) == 0 {
+		return LoadStats{}
+	}
+
+	stats := LoadStats{Min: math.MaxInt}
+	total := 0
+	for _, n := range counts {
+		total += n
+		stats.Min = min(stats.Min, n)
+		stats.Max = max(stats.Max, n)
+	}
+	stats.Mean = float64(total) / float64(len(counts))
+	var variance float64
+	for _, n := range counts {
+		d := float64(n) - stats.Mean
+		variance += d * d
+	}
+	stats.StdDev = math.Sqrt(variance / float64(len(counts)))
+	return stats
+}
@@ -0,0 +1,61 @@
+/*
+Copyright 2013 Google Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package consistenthash
+
+import (
+	"fmt"
+	"strconv"
+	"testing"
+)
+
+func TestDistribution(t *testing.T) {
+	hash := New(3, func(key []byte) uint32 {
+		i, err := strconv.Atoi(string(key))
+		if err != nil {
+			panic(err)
+		}
+		return uint32(i)
+	})
+	hash.ReplicaKey = LegacyReplicaKey
+	// 在哈希环生成[2, 4, 6, 8, 12, 14, 16, 18, 22, 24, 26, 28]
+	hash.Add("6", "4", "2", "8")
+
+	var keys []string
+	for i := 0; i < 8; i++ {
+		keys = append(keys, strconv.Itoa(i))
+	}
+	// 0-2分配到2，3-4分配到4，5-6分配到6，7分配到8
+	if got, want := fmt.Sprint(hash.Distribution(keys)), "map[2:3 4:2 6:2 8:1]"; got != want {
+		t.Errorf("Distribution = %s; want %s", got, want)
+	}
+
+	stats := hash.DistributionStats(keys)
+	if stats.Mean != 2 || stats.Min != 1 || stats.Max != 3 {
+		t.Errorf("DistributionStats = %+v; want mean 2, min 1, max 3", stats)
+	}
+	if want := 0.7071; stats.StdDev < want-0.001 || stats.StdDev > want+0.001 {
+		t.Errorf("StdDev = %v; want about %v", stats.StdDev, want)
+	}
+
+	// 没有分配到key的节点数量为0
+	if got, want := fmt.Sprint(hash.Distribution(nil)), "map[2:0 4:0 6:0 8:0]"; got != want {
+		t.Errorf("Distribution(nil) = %s; want %s", got, want)
+	}
+	if got := New(3, nil).DistributionStats(keys); got != (LoadStats{}) {
+		t.Errorf("DistributionStats on an empty ring = %+v; want zero", got)
+	}
+}
@@ -0,0 +1,158 @@
+/*
+Copyright 2013 Google Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package consistenthash
+
+import (
+	"sort"
+	"sync"
+)
+
+type Hash64 func(data []byte) uint64
+
+// 使用64位哈希值的哈希环，节点和虚拟节点很多时哈希值更分散，不容易冲突
+// 是并发安全的，64位哈希值几乎不会冲突，冲突时保留名称最小的节点
+type Map64 struct {
+	// 生成虚拟节点哈希值的字符串，为空时使用DefaultReplicaKey，和Map相同
+	ReplicaKey func(index int, node string) string
+
+	mu       sync.RWMutex
+	hash     Hash64            // 哈希算法
+	replicas int               // 为了让服务节点更加分散
+	keys     []uint64          // 哈希值列表
+	hashMap  map[uint64]string // 哈希值对应的服务节点
+	nodes    map[string]int    // 服务节点的虚拟节点数量
+}
+
+// 创建64位哈希值的哈希环数据结构，fn为空时使用FNV-1a算法，再经过fmix64混合
+// 只在末尾不同的字符串（例如DefaultReplicaKey生成的虚拟节点）FNV-1a的哈希值很集中，需要混合之后才能分散
+// 需要和旧版本保持相同的分配结果时，使用FNV64a和LegacyReplicaKey
+func New64(replicas int, fn Hash64) *Map64 {
+	m := &Map64{
+		replicas: replicas,
+		hash:     fn,
+		hashMap:  make(map[uint64]string),
+		nodes:    make(map[string]int),
+	}
+	if m.hash == nil {
+		m.hash = mixedFNV64a
+	}
+	return m
+}
+
+// FNV-1a哈希算法的64位版本，旧版本New64默认使用的哈希算法
+func FNV64a(data []byte) uint64 {
+	h := uint64(14695981039346656037)
+	for _, b := range data {
+		h ^= uint64(b)
+		h *= 1099511628211
+	}
+	return h
+}
+
+// FNV-1a的哈希值经过MurmurHash3的fmix64混合，每一位输入都会影响所有输出位
+func mixedFNV64a(data []byte) uint64 {
+	h := FNV64a(data)
+	h ^= h >> 33
+	h *= 0xff51afd7ed558ccd
+	h ^= h >> 33
+	h *= 0xc4ceb9fe1a85ec53
+	h ^= h >> 33
+	return h
+}
+
+// 计算节点第index个虚拟节点的哈希值
+func (m *Map64) replicaHash(index int, node string) uint64 {
+	if m.ReplicaKey != nil {
+		return m.hash([]byte(m.ReplicaKey(index, node)))
+	}
+	return m.hash([]byte(DefaultReplicaKey(index, node)))
+}
+
+// 判断节点个数是否为0
+func (m *Map64) IsEmpty() bool {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return len(m.keys) == 0
+}
+
+// 增加节点到哈希环
+func (m *Map64) Add(keys ...string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, key := range keys {
+		for i := 0; i < m.replicas; i++ {
+			hash := m.replicaHash(i, key)
+			owner, ok := m.hashMap[hash]
+			if !ok {
+				m.keys = append(m.keys, hash)
+				m.hashMap[hash] = key
+			} else if key < owner {
+				m.hashMap[hash] = key
+			}
+		}
+		m.nodes[key] = m.replicas
+	}
+	sort.Slice(m.keys, func(i, j int) bool { return m.keys[i] < m.keys[j] })
+}
+
+// 从哈希环移除节点，节点不存在时什么也不做
+func (m *Map64) Remove(keys ...string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	removed := false
+	for _, key := range keys {
+		n, ok := m.nodes[key]
+		if !ok {
+			continue
+		}
+		delete(m.nodes, key)
+		for i := 0; i < n; i++ {
+			hash := m.replicaHash(i, key)
+			if m.hashMap[hash] == key {
+				delete(m.hashMap, hash)
+				removed = true
+			}
+		}
+	}
+	if !removed {
+		return
+	}
+
+	hashes := m.keys[:0]
+	for _, hash := range m.keys {
+		if _, ok := m.hashMap[hash]; ok {
+			hashes = append(hashes, hash)
+		}
+	}
+	m.keys = hashes
+}
+
+// 获取key哈希值对应的服务节点
+func (m *Map64) Get(key string) string {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	if len(m.keys) == 0 {
+		return ""
+	}
+
+	hash := m.hash([]byte(key))
+	idx := sort.Search(len(m.keys), func(i int) bool { return m.keys[i] >= hash })
+	if idx == len(m.keys) {
+		idx = 0
+	}
+	return m.hashMap[m.keys[idx]]
+}
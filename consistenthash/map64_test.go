@@ -0,0 +1,89 @@
+/*
+Copyright 2013 Google Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package consistenthash
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestMap64(t *testing.T) {
+	hash1 := New64(100, nil)
+	hash1.Add("Bill", "Bob", "Bonny", "Becky")
+	hash2 := New64(100, nil)
+	hash2.Add("Becky", "Bonny", "Bob", "Bill")
+
+	const n = 10000
+	counts := make(map[string]int)
+	for i := 0; i < n; i++ {
+		key := fmt.Sprint("key", i)
+		node := hash1.Get(key)
+		// 节点相同的哈希环分配结果相同
+		if other := hash2.Get(key); node != other {
+			t.Fatalf("Get(%s) = %s and %s on rings with the same nodes", key, node, other)
+		}
+		counts[node]++
+	}
+	for _, node := range []string{"Bill", "Bob", "Bonny", "Becky"} {
+		if counts[node] < n/10 {
+			t.Errorf("node %s got %d keys; want at least %d", node, counts[node], n/10)
+		}
+	}
+
+	hash1.Remove("Bob", "Ben")
+	for i := 0; i < n; i++ {
+		key := fmt.Sprint("key", i)
+		if node := hash1.Get(key); node == "Bob" {
+			t.Fatalf("Get(%s) returned the removed node", key)
+		} else if before := hash2.Get(key); before != "Bob" && node != before {
+			t.Fatalf("Get(%s) moved from %s to %s after removing another node", key, before, node)
+		}
+	}
+
+	hash1.Remove("Bill", "Bonny", "Becky")
+	if !hash1.IsEmpty() || hash1.Get("key") != "" {
+		t.Error("ring not empty after removing every node")
+	}
+}
+
+// 默认的虚拟节点格式没有歧义，LegacyReplicaKey保持旧版本的分配结果
+func TestMap64ReplicaKey(t *testing.T) {
+	legacy := New64(11, nil)
+	legacy.ReplicaKey = LegacyReplicaKey
+	legacy.Add("0", "")
+	if got := len(legacy.keys); got != 21 {
+		t.Errorf("got %d hashes with the legacy format; want 21", got)
+	}
+
+	hash := New64(11, nil)
+	hash.Add("0", "")
+	if got := len(hash.keys); got != 22 {
+		t.Errorf("got %d hashes with the default format; want 22", got)
+	}
+	hash.Remove("")
+	if got := len(hash.keys); got != 11 {
+		t.Errorf("got %d hashes after Remove; want 11", got)
+	}
+
+	// 旧版本的哈希算法和格式
+	old := New64(100, FNV64a)
+	old.ReplicaKey = LegacyReplicaKey
+	old.Add("Bill", "Bob")
+	if got, want := old.hashMap[FNV64a([]byte("7Bob"))], "Bob"; got != want {
+		t.Errorf("legacy ring owner = %q; want %q", got, want)
+	}
+}
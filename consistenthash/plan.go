@@ -0,0 +1,99 @@
+/*
+Copyright 2013 Google Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package consistenthash
+
+import "math"
+
+// 估计增加节点之后哈希环上所属节点发生变化的比例，不会修改哈希环
+// 比例近似于需要重新分配的key的比例
+func (m *Map) PlanAdd(key string) float64 {
+	next := m.clone()
+	next.Add(key)
+	return m.changed(next)
+}
+
+// 估计移除节点之后哈希环上所属节点发生变化的比例，不会修改哈希环
+func (m *Map) PlanRemove(key string) float64 {
+	next := m.clone()
+	next.Remove(key)
+	return m.changed(next)
+}
+
+// 复制哈希环
+func (m *Map) clone() *Map {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	c := &Map{
+		ReplicaKey: m.ReplicaKey,
+		hash:       m.hash,
+		replicas:   m.replicas,
+		epsilon:    m.epsilon,
+		keys:       append([]uint32(nil), m.keys...),
+		hashMap:    make(map[uint32]string, len(m.hashMap)),
+		nodes:      make(map[string]int, len(m.nodes)),
+		collisions: make(map[uint32][]string, len(m.collisions)),
+	}
+	for hash, node := range m.hashMap {
+		c.hashMap[hash] = node
+	}
+	for node, n := range m.nodes {
+		c.nodes[node] = n
+	}
+	for hash, nodes := range m.collisions {
+		c.collisions[hash] = append([]string(nil), nodes...)
+	}
+	return c
+}
+
+// 计算两个哈希环上所属节点不同的比例
+// 两个哈希环的哈希值把环分成多段，每一段在各自的哈希环上属于同一个节点
+func (m *Map) changed(next *Map) float64 {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	bounds := make([]uint32, 0, len(m.keys)+len(next.keys))
+	bounds = append(bounds, m.keys...)
+	bounds = append(bounds, next.keys...)
+	if len(bounds) == 0 {
+		return 0
+	}
+	sortHashes(bounds)
+
+	const circle = 1 << 32
+	var changed float64
+	prev := int64(bounds[len(bounds)-1]) - circle
+	for _, b := range bounds {
+		// 哈希值(prev, b]属于b对应的节点
+		if int64(b) > prev && m.owner(b) != next.owner(b) {
+			changed += float64(int64(b) - prev)
+		}
+		prev = int64(b)
+	}
+	return math.Min(changed/circle, 1)
+}
+
+// 获取哈希值所属的节点，调用时需要持有读锁
+func (m *Map) owner(hash uint32) string {
+	if len(m.keys) == 0 {
+		return ""
+	}
+	idx := searchHash(m.keys, hash)
+	if idx == len(m.keys) {
+		idx = 0
+	}
+	return m.hashMap[m.keys[idx]]
+}
@@ -0,0 +1,84 @@
+/*
+Copyright 2013 Google Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package consistenthash
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+	"fmt"
+	"testing"
+)
+
+// 分布均匀的哈希算法，crc32在虚拟节点名称相似时分布不够均匀
+func uniformHash(data []byte) uint32 {
+	sum := sha256.Sum256(data)
+	return binary.BigEndian.Uint32(sum[:])
+}
+
+// 在N个节点的均匀哈希环上增加1个节点，大约1/(N+1)的范围发生变化
+func TestPlanAdd(t *testing.T) {
+	hash := New(200, uniformHash)
+	const n = 4
+	for i := 0; i < n; i++ {
+		hash.Add(fmt.Sprint("node", i))
+	}
+
+	want := 1.0 / (n + 1)
+	if got := hash.PlanAdd("new"); got < want*0.8 || got > want*1.2 {
+		t.Errorf("PlanAdd = %v; want about %v", got, want)
+	}
+	// 不会修改哈希环
+	if got := hash.Count(); got != n {
+		t.Errorf("Count = %d after PlanAdd; want %d", got, n)
+	}
+
+	// 移除节点的变化比例接近这个节点的负载
+	want = 1.0 / n
+	if got := hash.PlanRemove("node0"); got < want*0.8 || got > want*1.2 {
+		t.Errorf("PlanRemove = %v; want about %v", got, want)
+	}
+	if got := hash.PlanRemove("missing"); got != 0 {
+		t.Errorf("PlanRemove of a missing node = %v; want 0", got)
+	}
+	if got := hash.Count(); got != n {
+		t.Errorf("Count = %d after PlanRemove; want %d", got, n)
+	}
+
+	// 空的哈希环增加节点时整个环都发生变化
+	if got := New(3, nil).PlanAdd("new"); got != 1 {
+		t.Errorf("PlanAdd on an empty ring = %v; want 1", got)
+	}
+}
+
+// 复制的哈希环使用相同的虚拟节点格式
+func TestPlanReplicaKey(t *testing.T) {
+	hash := New(200, uniformHash)
+	hash.ReplicaKey = LegacyReplicaKey
+	const n = 4
+	for i := 0; i < n; i++ {
+		hash.Add(fmt.Sprint("node", i))
+	}
+
+	want := 1.0 / n
+	if got := hash.PlanRemove("node0"); got < want*0.8 || got > want*1.2 {
+		t.Errorf("PlanRemove = %v; want about %v", got, want)
+	}
+	want = 1.0 / (n + 1)
+	if got := hash.PlanAdd("new"); got < want*0.8 || got > want*1.2 {
+		t.Errorf("PlanAdd = %v; want about %v", got, want)
+	}
+}
@@ -0,0 +1,88 @@
+/*
+Copyright 2013 Google Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package consistenthash
+
+import (
+	"bytes"
+	"encoding/gob"
+	"fmt"
+)
+
+// 哈希环快照的数据结构，哈希算法是函数，无法保存
+type snapshot struct {
+	Replicas   int
+	Epsilon    float64
+	Nodes      map[string]int // 服务节点的虚拟节点数量，包含权重
+	Keys       []uint32
+	HashMap    map[uint32]string
+	Collisions map[uint32][]string
+}
+
+// 使用gob编码哈希环，包括replicas、服务节点和虚拟节点数量、哈希值列表
+// 实现encoding.BinaryMarshaler接口
+func (m *Map) MarshalBinary() ([]byte, error) {
+	m.mu.RLock()
+	snap := snapshot{
+		Replicas:   m.replicas,
+		Epsilon:    m.epsilon,
+		Nodes:      m.nodes,
+		Keys:       m.keys,
+		HashMap:    m.hashMap,
+		Collisions: m.collisions,
+	}
+	var buf bytes.Buffer
+	err := gob.NewEncoder(&buf).Encode(&snap)
+	m.mu.RUnlock()
+	if err != nil {
+		return nil, fmt.Errorf("consistenthash: encoding snapshot: %v", err)
+	}
+	return buf.Bytes(), nil
+}
+
+// 从MarshalBinary编码的数据恢复哈希环，替换哈希环中已有的节点
+// 哈希算法和ReplicaKey不会被保存，需要先用New设置和保存时相同的哈希算法和ReplicaKey，
+// 否则Get和Remove的结果是错误的
+// 实现encoding.BinaryUnmarshaler接口
+func (m *Map) UnmarshalBinary(data []byte) error {
+	var snap snapshot
+	if err := gob.NewDecoder(bytes.NewReader(data)).Decode(&snap); err != nil {
+		return fmt.Errorf("consistenthash: decoding snapshot: %v", err)
+	}
+	// gob不会编码空的map
+	if snap.Nodes == nil {
+		snap.Nodes = make(map[string]int)
+	}
+	if snap.HashMap == nil {
+		snap.HashMap = make(map[uint32]string)
+	}
+	if snap.Collisions == nil {
+		snap.Collisions = make(map[uint32][]string)
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.hash == nil {
+		return fmt.Errorf("consistenthash: restoring a snapshot into a Map not created by New")
+	}
+	m.replicas = snap.Replicas
+	m.epsilon = snap.Epsilon
+	m.nodes = snap.Nodes
+	m.keys = snap.Keys
+	m.hashMap = snap.HashMap
+	m.collisions = snap.Collisions
+	return nil
+}
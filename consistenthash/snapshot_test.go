@@ -0,0 +1,77 @@
+/*
+Copyright 2013 Google Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package consistenthash
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestMarshalBinary(t *testing.T) {
+	hash := New(50, nil)
+	hash.Add("Bill", "Bob")
+	hash.AddWeighted("Bonny", 3)
+	hash.AddReplicas("Becky", 10)
+
+	data, err := hash.MarshalBinary()
+	if err != nil {
+		t.Fatal(err)
+	}
+	restored := New(1, nil)
+	restored.Add("Ben")
+	if err := restored.UnmarshalBinary(data); err != nil {
+		t.Fatal(err)
+	}
+
+	for i := 0; i < 1000; i++ {
+		key := fmt.Sprint("key", i)
+		if got, want := restored.Get(key), hash.Get(key); got != want {
+			t.Fatalf("Get(%s) = %s after restore; want %s", key, got, want)
+		}
+	}
+	if got, want := fmt.Sprint(restored.Members()), "[Becky Bill Bob Bonny]"; got != want {
+		t.Errorf("Members = %s; want %s", got, want)
+	}
+
+	// 恢复之后移除带有权重的节点，同样移除所有虚拟节点
+	restored.Remove("Bonny")
+	if got, want := len(restored.keys), 2*50+10; got != want {
+		t.Errorf("got %d hashes after Remove; want %d", got, want)
+	}
+	// 恢复之后可以继续添加节点
+	restored.Add("Ben")
+	if !restored.Has("Ben") {
+		t.Error("Has(Ben) = false after Add")
+	}
+
+	// 空的哈希环
+	data, err = New(3, nil).MarshalBinary()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := restored.UnmarshalBinary(data); err != nil {
+		t.Fatal(err)
+	}
+	restored.Add("Ben")
+	if got := restored.Get("key"); got != "Ben" {
+		t.Errorf("Get = %s; want Ben", got)
+	}
+
+	if err := restored.UnmarshalBinary([]byte("garbage")); err == nil {
+		t.Error("UnmarshalBinary of garbage succeeded; want an error")
+	}
+}
@@ -70,6 +70,13 @@ type HTTPPoolOptions struct {
 	// HashFn specifies the hash function of the consistent hash.
 	// If blank, it defaults to crc32.ChecksumIEEE.
 	HashFn consistenthash.Hash
+
+	// ReplicaKey specifies how the consistent hash names each key replica.
+	// If blank, it defaults to consistenthash.DefaultReplicaKey.
+	// Earlier versions used consistenthash.LegacyReplicaKey; set it to keep
+	// the same key owners while upgrading peers one at a time, since every
+	// peer must use the same format.
+	ReplicaKey func(index int, node string) string
 }
 
 // NewHTTPPool initializes an HTTP pool of peers, and registers itself as a PeerPicker.
@@ -106,7 +113,7 @@ func NewHTTPPoolOpts(self string, o *HTTPPoolOptions) *HTTPPool {
 	if p.opts.Replicas == 0 {
 		p.opts.Replicas = defaultReplicas
 	}
-	p.peers = consistenthash.New(p.opts.Replicas, p.opts.HashFn)
+	p.peers = p.newPeers()
 
 	RegisterPeerPicker(func() PeerPicker { return p })
 	return p
@@ -118,7 +125,7 @@ func NewHTTPPoolOpts(self string, o *HTTPPoolOptions) *HTTPPool {
 func (p *HTTPPool) Set(peers ...string) {
 	p.mu.Lock()
 	defer p.mu.Unlock()
-	p.peers = consistenthash.New(p.opts.Replicas, p.opts.HashFn)
+	p.peers = p.newPeers()
 	p.peers.Add(peers...)
 	p.httpGetters = make(map[string]*httpGetter, len(peers))
 	for _, peer := range peers {
@@ -126,6 +133,13 @@ func (p *HTTPPool) Set(peers ...string) {
 	}
 }
 
+// newPeers returns an empty consistent hash configured by p.opts.
+func (p *HTTPPool) newPeers() *consistenthash.Map {
+	m := consistenthash.New(p.opts.Replicas, p.opts.HashFn)
+	m.ReplicaKey = p.opts.ReplicaKey
+	return m
+}
+
 func (p *HTTPPool) PickPeer(key string) (ProtoGetter, bool) {
 	p.mu.Lock()
 	defer p.mu.Unlock()
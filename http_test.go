@@ -29,6 +29,8 @@ import (
 	"sync"
 	"testing"
 	"time"
+
+	"github.com/golang/groupcache/consistenthash"
 )
 
 var (
@@ -110,6 +112,32 @@ func testKeys(n int) (keys []string) {
 	return
 }
 
+func TestHTTPPoolReplicaKey(t *testing.T) {
+	peers := []string{"http://10.0.0.1:8008", "http://10.0.0.2:8008", "http://10.0.0.3:8008"}
+	p := &HTTPPool{opts: HTTPPoolOptions{Replicas: defaultReplicas, ReplicaKey: consistenthash.LegacyReplicaKey}}
+	p.Set(peers...)
+
+	legacy := consistenthash.New(defaultReplicas, nil)
+	legacy.ReplicaKey = consistenthash.LegacyReplicaKey
+	legacy.Add(peers...)
+	def := consistenthash.New(defaultReplicas, nil)
+	def.Add(peers...)
+
+	moved := 0
+	for i := 0; i < 1000; i++ {
+		key := strconv.Itoa(i)
+		if got, want := p.peers.Get(key), legacy.Get(key); got != want {
+			t.Fatalf("peer for %q = %s; want %s", key, got, want)
+		}
+		if legacy.Get(key) != def.Get(key) {
+			moved++
+		}
+	}
+	if moved == 0 {
+		t.Error("default and legacy replica keys picked the same peers for every key")
+	}
+}
+
 func beChildForTestHTTPPool() {
 	addrs := strings.Split(*peerAddrs, ",")
 
@@ -0,0 +1,196 @@
+/*
+Copyright 2013 Google Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package lru
+
+import "container/list"
+
+// ARC是自适应替换缓存的实现，不是并发安全的
+// T1保存只访问过一次的键值，T2保存访问过多次的键值
+// B1和B2是对应的幽灵队列，只保存被移除的键，命中幽灵队列时调整T1的目标大小p
+// B1命中说明最近访问更重要，p增大；B2命中说明访问频率更重要，p减小
+type ARC struct {
+	// 缓存元素被移除的时候触发的回调函数，移除幽灵键不会触发
+	OnEvicted func(key Key, value interface{})
+
+	size int // 缓存元素的最大数量
+	p    int // T1的目标大小
+
+	t1, t2 *list.List // 缓存的键值，最近使用的在最前面
+	b1, b2 *list.List // 幽灵队列，只保存键
+
+	cache map[interface{}]*list.Element // 四个队列中所有的键
+}
+
+// ARC的键值对，记录所在的队列
+type arcEntry struct {
+	key   Key
+	value interface{}
+	list  *list.List
+}
+
+// ARC结构的构造函数
+func NewARC(size int) *ARC {
+	if size <= 0 {
+		panic("lru: ARC size must be positive")
+	}
+	return &ARC{
+		size:  size,
+		t1:    list.New(),
+		t2:    list.New(),
+		b1:    list.New(),
+		b2:    list.New(),
+		cache: make(map[interface{}]*list.Element),
+	}
+}
+
+// 添加键值到缓存
+func (c *ARC) Add(key Key, value interface{}) {
+	if ele, ok := c.cache[key]; ok {
+		kv := ele.Value.(*arcEntry)
+		switch kv.list {
+		case c.t1, c.t2:
+			// 如果键值已缓存，更新value并移动到T2的最前面
+			kv.value = value
+			c.move(ele, c.t2)
+		case c.b1:
+			// B1命中，增大T1的目标大小
+			c.p = min(c.size, c.p+max(c.b2.Len()/c.b1.Len(), 1))
+			c.replace(false)
+			kv.value = value
+			c.move(ele, c.t2)
+		case c.b2:
+			// B2命中，减小T1的目标大小
+			c.p = max(0, c.p-max(c.b1.Len()/c.b2.Len(), 1))
+			c.replace(true)
+			kv.value = value
+			c.move(ele, c.t2)
+		}
+		return
+	}
+
+	// 新键值放入T1，先保证幽灵队列和缓存的总大小不超过限制
+	if l1 := c.t1.Len() + c.b1.Len(); l1 == c.size {
+		if c.t1.Len() < c.size {
+			c.removeGhost(c.b1)
+			c.replace(false)
+		} else {
+			c.removeElement(c.t1.Back())
+		}
+	} else if total := l1 + c.t2.Len() + c.b2.Len(); total >= c.size {
+		if total == 2*c.size {
+			c.removeGhost(c.b2)
+		}
+		c.replace(false)
+	}
+	kv := &arcEntry{key: key, value: value, list: c.t1}
+	c.cache[key] = c.t1.PushFront(kv)
+}
+
+// 从T1或者T2中移除一个键值，保留键到对应的幽灵队列
+// T1超出目标大小时从T1移除，否则从T2移除
+func (c *ARC) replace(inB2 bool) {
+	if c.t1.Len() > 0 && (c.t1.Len() > c.p || (inB2 && c.t1.Len() == c.p)) {
+		c.evict(c.t1.Back(), c.b1)
+	} else if c.t2.Len() > 0 {
+		c.evict(c.t2.Back(), c.b2)
+	}
+}
+
+// 移除缓存的键值，只保留键到幽灵队列
+func (c *ARC) evict(e *list.Element, ghost *list.List) {
+	kv := e.Value.(*arcEntry)
+	value := kv.value
+	kv.value = nil
+	c.move(e, ghost)
+	if c.OnEvicted != nil {
+		c.OnEvicted(kv.key, value)
+	}
+}
+
+// 移除幽灵队列中最老的键
+func (c *ARC) removeGhost(ghost *list.List) {
+	if e := ghost.Back(); e != nil {
+		ghost.Remove(e)
+		delete(c.cache, e.Value.(*arcEntry).key)
+	}
+}
+
+// 将元素移动到另一个队列的最前面
+func (c *ARC) move(e *list.Element, to *list.List) {
+	kv := e.Value.(*arcEntry)
+	if kv.list == to {
+		to.MoveToFront(e)
+		return
+	}
+	kv.list.Remove(e)
+	kv.list = to
+	c.cache[kv.key] = to.PushFront(kv)
+}
+
+// 从缓存中获取键值，命中时移动到T2的最前面
+func (c *ARC) Get(key Key) (value interface{}, ok bool) {
+	ele, hit := c.cache[key]
+	if !hit {
+		return
+	}
+	kv := ele.Value.(*arcEntry)
+	if kv.list != c.t1 && kv.list != c.t2 {
+		return
+	}
+	c.move(ele, c.t2)
+	return kv.value, true
+}
+
+// 从缓存中移除键值，同时清除幽灵队列中的键
+func (c *ARC) Remove(key Key) {
+	if ele, hit := c.cache[key]; hit {
+		c.removeElement(ele)
+	}
+}
+
+// 从所在的队列中移除键值，只有缓存的键值会触发回调
+func (c *ARC) removeElement(e *list.Element) {
+	kv := e.Value.(*arcEntry)
+	kv.list.Remove(e)
+	delete(c.cache, kv.key)
+	if c.OnEvicted != nil && (kv.list == c.t1 || kv.list == c.t2) {
+		c.OnEvicted(kv.key, kv.value)
+	}
+}
+
+// 获取缓存的元素数量，不包括幽灵队列中的键
+func (c *ARC) Len() int {
+	return c.t1.Len() + c.t2.Len()
+}
+
+// 重置缓存，清除所有元素和幽灵队列
+func (c *ARC) Clear() {
+	if c.OnEvicted != nil {
+		for _, e := range c.cache {
+			kv := e.Value.(*arcEntry)
+			if kv.list == c.t1 || kv.list == c.t2 {
+				c.OnEvicted(kv.key, kv.value)
+			}
+		}
+	}
+	c.p = 0
+	c.t1.Init()
+	c.t2.Init()
+	c.b1.Init()
+	c.b2.Init()
+	c.cache = make(map[interface{}]*list.Element)
+}
@@ -0,0 +1,97 @@
+/*
+Copyright 2013 Google Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package lru
+
+import (
+	"math/rand"
+	"testing"
+)
+
+func TestARCAdaptation(t *testing.T) {
+	c := NewARC(4)
+	c.Add("a", 1)
+	c.Add("b", 2)
+	c.Get("a")
+	c.Get("b")
+	c.Add("c", 3)
+	c.Add("d", 4)
+	// 缓存已满，c从T1移到B1
+	c.Add("e", 5)
+	if _, ok := c.Get("c"); ok {
+		t.Fatal("c should have been moved to B1")
+	}
+
+	// B1命中，p向最近访问的方向增大
+	c.Add("c", 3)
+	if c.p != 1 {
+		t.Fatalf("got p = %d after B1 hit; want 1", c.p)
+	}
+
+	// a从T2移到B2
+	c.Add("f", 6)
+	if _, ok := c.Get("a"); ok {
+		t.Fatal("a should have been moved to B2")
+	}
+
+	// B2命中，p向访问频率的方向减小
+	c.Add("a", 1)
+	if c.p != 0 {
+		t.Fatalf("got p = %d after B2 hit; want 0", c.p)
+	}
+	if val, ok := c.Get("a"); !ok || val != 1 {
+		t.Fatalf("Get(a) = %v, %v; want 1, true", val, ok)
+	}
+}
+
+func TestARCCapacity(t *testing.T) {
+	evicted := 0
+	c := NewARC(16)
+	c.OnEvicted = func(key Key, value interface{}) {
+		if value == nil {
+			t.Fatalf("OnEvicted called for ghost key %v", key)
+		}
+		evicted++
+	}
+
+	r := rand.New(rand.NewSource(1))
+	for i := 0; i < 10000; i++ {
+		key := r.Intn(64)
+		if r.Intn(2) == 0 {
+			c.Get(key)
+		} else {
+			c.Add(key, i)
+		}
+		if got := c.Len(); got > 16 {
+			t.Fatalf("got %d entries; want at most 16", got)
+		}
+		if got := c.b1.Len() + c.b2.Len() + c.Len(); got > 32 {
+			t.Fatalf("got %d entries including ghosts; want at most 32", got)
+		}
+	}
+	if evicted == 0 {
+		t.Fatal("no entries were evicted")
+	}
+
+	c.Remove(0)
+	if _, ok := c.Get(0); ok {
+		t.Fatal("Get returned a removed entry")
+	}
+	c.Clear()
+	if got := c.Len(); got != 0 {
+		t.Fatalf("got %d entries after Clear; want 0", got)
+	}
+}
@@ -0,0 +1,122 @@
+/*
+Copyright 2013 Google Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package lru
+
+import "math"
+
+// 带有布隆过滤器的Cache结构的构造函数
+// Get、Peek和Contains先检查布隆过滤器，一定不存在的键直接视为未命中，不需要查找哈希表
+// 布隆过滤器按照maxEntries和falsePositiveRate分配，误判时继续查找哈希表，不影响结果
+// 布隆过滤器不能删除键，添加的键数量达到maxEntries的2倍时按照当前的键值重建
+// Go的哈希表查找本身很快，布隆过滤器不一定更快，使用之前先用BenchmarkGetMissBloom对比
+// 相等的键得到相同的哈希值，布隆过滤器判断不存在的键一定不在缓存中
+func NewWithBloomFilter(maxEntries int, falsePositiveRate float64) *Cache {
+	if maxEntries <= 0 {
+		panic("lru: bloom filter requires a positive maxEntries")
+	}
+	c := New(maxEntries)
+	c.bloom = newBloomFilter(maxEntries, falsePositiveRate)
+	return c
+}
+
+// 布隆过滤器，使用双重哈希计算k个位置
+type bloomFilter struct {
+	bits     []uint64
+	mask     uint32 // 位的数量减1，位的数量是2的幂
+	k        uint32 // 哈希函数的数量
+	adds     int    // 重建之后添加的键数量
+	capacity int    // adds达到capacity时需要重建
+}
+
+func newBloomFilter(n int, p float64) *bloomFilter {
+	if p <= 0 || p >= 1 {
+		p = 0.01
+	}
+	m := math.Ceil(-float64(n) * math.Log(p) / (math.Ln2 * math.Ln2))
+	k := math.Round(m / float64(n) * math.Ln2)
+	if k < 1 {
+		k = 1
+	}
+	// 位的数量向上取整到2的幂，用位运算代替取模
+	bits := 64
+	for float64(bits) < m {
+		bits <<= 1
+	}
+	return &bloomFilter{
+		bits:     make([]uint64, bits/64),
+		mask:     uint32(bits - 1),
+		k:        uint32(k),
+		capacity: 2 * n,
+	}
+}
+
+// 计算键的两个哈希值，第二个哈希值是奇数
+func (b *bloomFilter) hashes(key Key) (h1, h2 uint32) {
+	h1 = hashKey(key)
+	h2 = (h1>>16|h1<<16)*prime32 | 1
+	return h1, h2
+}
+
+func (b *bloomFilter) add(key Key) {
+	h1, h2 := b.hashes(key)
+	for i := uint32(0); i < b.k; i++ {
+		n := (h1 + i*h2) & b.mask
+		b.bits[n/64] |= 1 << (n % 64)
+	}
+	b.adds++
+}
+
+// 判断键是否可能存在，返回false时一定不存在
+func (b *bloomFilter) has(key Key) bool {
+	h1, h2 := b.hashes(key)
+	for i := uint32(0); i < b.k; i++ {
+		n := (h1 + i*h2) & b.mask
+		if b.bits[n/64]&(1<<(n%64)) == 0 {
+			return false
+		}
+	}
+	return true
+}
+
+func (b *bloomFilter) reset() {
+	for i := range b.bits {
+		b.bits[i] = 0
+	}
+	b.adds = 0
+}
+
+func (b *bloomFilter) clone() *bloomFilter {
+	clone := *b
+	clone.bits = append([]uint64(nil), b.bits...)
+	return &clone
+}
+
+// 记录新添加的键，添加的键太多时按照双向链表中的键重建布隆过滤器
+func (c *Cache) bloomAdd(key Key) {
+	if c.bloom.adds >= c.bloom.capacity {
+		c.bloom.reset()
+		for e := c.ll.Front(); e != nil; e = e.Next() {
+			c.bloom.add(e.Value.(*entry).key)
+		}
+	}
+	c.bloom.add(key)
+}
+
+// 判断键是否一定不在缓存中
+func (c *Cache) definitelyAbsent(key Key) bool {
+	return c.bloom != nil && !c.bloom.has(key)
+}
@@ -0,0 +1,110 @@
+/*
+Copyright 2013 Google Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package lru
+
+import (
+	"fmt"
+	"math"
+	"testing"
+)
+
+func TestBloomFilter(t *testing.T) {
+	lru := NewWithBloomFilter(100, 0.01)
+	for i := 0; i < 100; i++ {
+		lru.Add(i, i)
+	}
+	for i := 0; i < 100; i++ {
+		if _, ok := lru.Get(i); !ok {
+			t.Fatalf("Get(%d) missed a cached key", i)
+		}
+	}
+
+	// 误判的比例接近falsePositiveRate
+	falsePositives := 0
+	for i := 100; i < 10100; i++ {
+		if lru.bloom.has(i) {
+			falsePositives++
+		}
+		if _, ok := lru.Get(i); ok {
+			t.Fatalf("Get(%d) hit a missing key", i)
+		}
+	}
+	if falsePositives > 300 {
+		t.Fatalf("got %d false positives in 10000 lookups; want about 100", falsePositives)
+	}
+
+	lru.Clear()
+	if lru.bloom.has(1) && lru.bloom.has(2) && lru.bloom.has(3) {
+		t.Fatal("bloom filter was not reset by Clear")
+	}
+}
+
+// 添加的键太多时重建布隆过滤器，被移除的键不再占用
+func TestBloomFilterRebuild(t *testing.T) {
+	lru := NewWithBloomFilter(10, 0.01)
+	for i := 0; i < 1000; i++ {
+		lru.Add(fmt.Sprint(i), i)
+	}
+	for i := 990; i < 1000; i++ {
+		if !lru.Contains(fmt.Sprint(i)) {
+			t.Fatalf("Contains(%d) = false for a cached key", i)
+		}
+	}
+	if lru.bloom.adds > lru.bloom.capacity {
+		t.Fatalf("got %d adds since rebuild; want at most %d", lru.bloom.adds, lru.bloom.capacity)
+	}
+}
+
+// 相等的键不会被布隆过滤器判断为不存在
+func TestBloomFilterKeyHash(t *testing.T) {
+	type node struct{ n int }
+	lru := NewWithBloomFilter(100, 0.01)
+	n := &node{1}
+	lru.Add(n, 1)
+	n.n = 2
+	if _, ok := lru.Get(n); !ok {
+		t.Fatal("Get missed a cached pointer key after its pointee changed")
+	}
+
+	lru.Add(0.0, 0)
+	if _, ok := lru.Get(math.Copysign(0, -1)); !ok {
+		t.Fatal("Get(-0.0) missed after Add(0.0)")
+	}
+}
+
+// 对比未命中为主时使用和不使用布隆过滤器的性能
+func BenchmarkGetMissBloom(b *testing.B) {
+	benchmarkGetMiss(b, NewWithBloomFilter(1000, 0.01))
+}
+
+func BenchmarkGetMiss(b *testing.B) {
+	benchmarkGetMiss(b, New(1000))
+}
+
+func benchmarkGetMiss(b *testing.B, lru *Cache) {
+	for i := 0; i < 1000; i++ {
+		lru.Add(fmt.Sprint("myKey", i), i)
+	}
+	keys := make([]string, 1000)
+	for i := range keys {
+		keys[i] = fmt.Sprint("nonsense", i)
+	}
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		lru.Get(keys[i%len(keys)])
+	}
+}
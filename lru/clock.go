@@ -0,0 +1,170 @@
+/*
+Copyright 2013 Google Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package lru
+
+import (
+	"sync"
+	"sync/atomic"
+)
+
+// ClockCache是CLOCK（second-chance）算法的实现，是并发安全的
+// 键值保存在环形数组中，Get只设置键值的访问位，不需要移动双向链表的元素，只获取读锁
+// 缓存已满时，指针沿着环形数组移动，清除遇到的访问位，直到找到没有被访问过的键值并移除
+// 命名为ClockCache，避免和表示时间来源的Clock混淆
+type ClockCache struct {
+	// 缓存元素被移除的时候触发的回调函数，需要在使用缓存之前设置，在释放锁之后调用
+	OnEvicted func(key Key, value interface{})
+
+	mu    sync.RWMutex
+	slots []clockSlot
+	index map[interface{}]int // 键所在的位置
+	free  []int               // 空闲的位置
+	hand  int                 // 下一个检查的位置
+}
+
+// 环形数组中的一个位置
+type clockSlot struct {
+	key   Key
+	value interface{}
+	used  bool
+	// 访问位，持有读锁时通过原子操作设置
+	ref uint32
+}
+
+// ClockCache结构的构造函数，size是缓存元素的最大数量
+func NewClockCache(size int) *ClockCache {
+	if size <= 0 {
+		panic("lru: ClockCache size must be positive")
+	}
+	c := &ClockCache{slots: make([]clockSlot, size)}
+	c.reset()
+	return c
+}
+
+// 清空环形数组，所有位置都是空闲的
+func (c *ClockCache) reset() {
+	for i := range c.slots {
+		c.slots[i] = clockSlot{}
+	}
+	c.index = make(map[interface{}]int, len(c.slots))
+	c.free = c.free[:0]
+	for i := len(c.slots) - 1; i >= 0; i-- {
+		c.free = append(c.free, i)
+	}
+	c.hand = 0
+}
+
+// 添加键值到缓存，已缓存的键值更新value并设置访问位
+func (c *ClockCache) Add(key Key, value interface{}) {
+	c.mu.Lock()
+	if i, ok := c.index[key]; ok {
+		c.slots[i].value = value
+		atomic.StoreUint32(&c.slots[i].ref, 1)
+		c.mu.Unlock()
+		return
+	}
+
+	var evicted clockSlot
+	var i int
+	if n := len(c.free); n > 0 {
+		i = c.free[n-1]
+		c.free = c.free[:n-1]
+	} else {
+		i = c.reclaim()
+		evicted = c.slots[i]
+		delete(c.index, evicted.key)
+	}
+	c.slots[i] = clockSlot{key: key, value: value, used: true}
+	c.index[key] = i
+	c.mu.Unlock()
+
+	if evicted.used && c.OnEvicted != nil {
+		c.OnEvicted(evicted.key, evicted.value)
+	}
+}
+
+// 移动指针，清除遇到的访问位，返回第一个没有被访问过的位置
+// 缓存已满时所有位置都在使用，最多绕两圈就能找到
+func (c *ClockCache) reclaim() int {
+	for {
+		i := c.hand
+		c.hand = (c.hand + 1) % len(c.slots)
+		s := &c.slots[i]
+		if atomic.LoadUint32(&s.ref) == 0 {
+			return i
+		}
+		atomic.StoreUint32(&s.ref, 0)
+	}
+}
+
+// 从缓存中获取键值，只设置访问位，不改变键值的位置
+func (c *ClockCache) Get(key Key) (value interface{}, ok bool) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	i, hit := c.index[key]
+	if !hit {
+		return
+	}
+	s := &c.slots[i]
+	if atomic.LoadUint32(&s.ref) == 0 {
+		atomic.StoreUint32(&s.ref, 1)
+	}
+	return s.value, true
+}
+
+// 从缓存中移除键值
+func (c *ClockCache) Remove(key Key) {
+	c.mu.Lock()
+	i, hit := c.index[key]
+	if !hit {
+		c.mu.Unlock()
+		return
+	}
+	evicted := c.slots[i]
+	c.slots[i] = clockSlot{}
+	delete(c.index, key)
+	c.free = append(c.free, i)
+	c.mu.Unlock()
+
+	if c.OnEvicted != nil {
+		c.OnEvicted(evicted.key, evicted.value)
+	}
+}
+
+// 获取缓存的元素数量
+func (c *ClockCache) Len() int {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return len(c.index)
+}
+
+// 重置缓存，清除所有元素
+func (c *ClockCache) Clear() {
+	c.mu.Lock()
+	var evicted []clockSlot
+	if c.OnEvicted != nil {
+		for _, i := range c.index {
+			evicted = append(evicted, c.slots[i])
+		}
+	}
+	c.reset()
+	c.mu.Unlock()
+
+	for _, s := range evicted {
+		c.OnEvicted(s.key, s.value)
+	}
+}
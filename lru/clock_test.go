@@ -0,0 +1,96 @@
+/*
+Copyright 2013 Google Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package lru
+
+import (
+	"fmt"
+	"testing"
+)
+
+// 被访问过的键值获得第二次机会
+func TestClockCacheSecondChance(t *testing.T) {
+	c := NewClockCache(3)
+	evictedKeys := make([]Key, 0)
+	c.OnEvicted = func(key Key, value interface{}) {
+		evictedKeys = append(evictedKeys, key)
+	}
+	c.Add("myKey1", 1)
+	c.Add("myKey2", 2)
+	c.Add("myKey3", 3)
+	c.Get("myKey1")
+
+	c.Add("myKey4", 4)
+	c.Add("myKey5", 5)
+	if got, want := fmt.Sprint(evictedKeys), "[myKey2 myKey3]"; got != want {
+		t.Fatalf("got evicted keys %s; want %s", got, want)
+	}
+
+	// myKey1的访问位已经被清除，下一次被移除
+	c.Add("myKey6", 6)
+	if _, ok := c.Get("myKey1"); ok {
+		t.Fatal("myKey1 got a third chance")
+	}
+	if got := c.Len(); got != 3 {
+		t.Fatalf("got %d entries; want 3", got)
+	}
+}
+
+func TestClockCacheRemove(t *testing.T) {
+	c := NewClockCache(2)
+	c.Add("myKey1", 1)
+	c.Add("myKey2", 2)
+	c.Remove("myKey1")
+
+	// 移除之后空出的位置可以直接使用，不会移除其他键值
+	c.Add("myKey3", 3)
+	if _, ok := c.Get("myKey2"); !ok {
+		t.Fatal("myKey2 was evicted although a slot was free")
+	}
+	c.Clear()
+	if got := c.Len(); got != 0 {
+		t.Fatalf("got %d entries after Clear; want 0", got)
+	}
+}
+
+// 对比读多写少时CLOCK和LRU的吞吐量
+func BenchmarkClockCacheParallelGet(b *testing.B) {
+	c := NewClockCache(1024)
+	for i := 0; i < 1024; i++ {
+		c.Add(i, i)
+	}
+	b.RunParallel(func(pb *testing.PB) {
+		i := 0
+		for pb.Next() {
+			c.Get(i & 1023)
+			i++
+		}
+	})
+}
+
+func BenchmarkSafeCacheParallelGet(b *testing.B) {
+	c := ThreadSafe(New(1024))
+	for i := 0; i < 1024; i++ {
+		c.Add(i, i)
+	}
+	b.RunParallel(func(pb *testing.PB) {
+		i := 0
+		for pb.Next() {
+			c.Get(i & 1023)
+			i++
+		}
+	})
+}
@@ -0,0 +1,77 @@
+/*
+Copyright 2013 Google Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package lru
+
+// 通过通道异步发送的被移除键值
+type Evicted struct {
+	Key    Key
+	Value  interface{}
+	Reason EvictReason
+}
+
+// 移除通道已满时的处理方式
+type EvictOverflow int
+
+const (
+	OverflowDropNewest EvictOverflow = iota // 丢弃新的事件
+	OverflowDropOldest                      // 丢弃通道中最老的事件，发送新的事件
+)
+
+// 创建带有移除通道的Cache，buffer是通道的缓冲区大小
+// 发送不会阻塞，通道已满时按照overflow丢弃事件，丢弃的数量记录在Stats.EvictDrops
+// 移除通道和OnEvicted可以同时使用，两者都会收到被移除的键值
+func NewWithEvictChan(maxEntries, buffer int, overflow EvictOverflow) *Cache {
+	c := New(maxEntries)
+	c.evictCh = make(chan Evicted, buffer)
+	c.evictOverflow = overflow
+	return c
+}
+
+// 获取移除通道，没有使用NewWithEvictChan创建或者已关闭时返回nil
+func (c *Cache) EvictChan() <-chan Evicted {
+	return c.evictCh
+}
+
+// 关闭移除通道，之后被移除的键值不再发送，可以重复调用
+func (c *Cache) CloseEvictChan() {
+	if c.evictCh != nil {
+		close(c.evictCh)
+		c.evictCh = nil
+	}
+}
+
+// 非阻塞地发送被移除的键值
+func (c *Cache) sendEvicted(ev Evicted) {
+	select {
+	case c.evictCh <- ev:
+		return
+	default:
+	}
+
+	if c.evictOverflow == OverflowDropOldest {
+		select {
+		case <-c.evictCh:
+		default:
+		}
+		select {
+		case c.evictCh <- ev:
+		default:
+			// 接收方同时在读取，或者缓冲区大小为0，只能丢弃新的事件
+		}
+	}
+	c.stats.EvictDrops++
+}
@@ -0,0 +1,82 @@
+/*
+Copyright 2013 Google Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package lru
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestEvictChan(t *testing.T) {
+	lru := NewWithEvictChan(1, 4, OverflowDropNewest)
+	evicted := 0
+	lru.OnEvicted = func(key Key, value interface{}) { evicted++ }
+
+	lru.Add("myKey1", 1)
+	lru.Add("myKey2", 2)
+	lru.Remove("myKey2")
+
+	// 通道和OnEvicted都收到被移除的键值
+	want := []Evicted{{"myKey1", 1, ReasonCapacity}, {"myKey2", 2, ReasonManual}}
+	for _, w := range want {
+		if got := <-lru.EvictChan(); got != w {
+			t.Fatalf("got %+v from EvictChan; want %+v", got, w)
+		}
+	}
+	if evicted != 2 {
+		t.Fatalf("got %d callbacks; want 2", evicted)
+	}
+
+	lru.CloseEvictChan()
+	lru.CloseEvictChan()
+	if lru.EvictChan() != nil {
+		t.Fatal("EvictChan is not nil after CloseEvictChan")
+	}
+	lru.Add("myKey3", 3)
+	lru.Add("myKey4", 4)
+}
+
+func TestEvictChanOverflow(t *testing.T) {
+	for _, tt := range []struct {
+		overflow EvictOverflow
+		want     string
+	}{
+		{OverflowDropNewest, "[myKey0 myKey1]"},
+		{OverflowDropOldest, "[myKey3 myKey4]"},
+	} {
+		lru := NewWithEvictChan(0, 2, tt.overflow)
+		for i := 0; i < 5; i++ {
+			lru.Add(fmt.Sprintf("myKey%d", i), i)
+		}
+		for i := 0; i < 5; i++ {
+			lru.RemoveOldest()
+		}
+
+		var keys []Key
+		ch := lru.EvictChan()
+		lru.CloseEvictChan()
+		for ev := range ch {
+			keys = append(keys, ev.Key)
+		}
+		if got := fmt.Sprint(keys); got != tt.want {
+			t.Fatalf("overflow %d: got keys %s; want %s", tt.overflow, got, tt.want)
+		}
+		if got := lru.Stats().EvictDrops; got != 3 {
+			t.Fatalf("overflow %d: got %d drops; want 3", tt.overflow, got)
+		}
+	}
+}
@@ -0,0 +1,85 @@
+/*
+Copyright 2013 Google Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package lru
+
+import (
+	"container/heap"
+	"sort"
+)
+
+// HotKeys返回的键和访问次数
+type HotKey struct {
+	Key   Key
+	Count int64
+}
+
+// 返回访问次数最多的topN个键，按照访问次数从多到少排序，次数相同时最近使用的在前
+// 访问次数包括Get命中、Touch和更新已缓存的键值，不改变键值的位置
+// 使用大小为topN的最小堆，时间复杂度是O(n log topN)
+func (c *Cache) HotKeys(topN int) []HotKey {
+	if c.cache == nil || topN <= 0 {
+		return nil
+	}
+	h := make(hotKeyHeap, 0, topN)
+	rank := 0
+	for e := c.ll.Front(); e != nil; e = e.Next() {
+		kv := e.Value.(*entry)
+		item := hotKeyItem{HotKey{kv.key, kv.hits}, rank}
+		rank++
+		if len(h) < topN {
+			heap.Push(&h, item)
+		} else if h.less(h[0], item) {
+			h[0] = item
+			heap.Fix(&h, 0)
+		}
+	}
+
+	sort.Slice(h, func(i, j int) bool { return h.less(h[j], h[i]) })
+	keys := make([]HotKey, len(h))
+	for i, item := range h {
+		keys[i] = item.HotKey
+	}
+	return keys
+}
+
+// 堆中的元素，rank是键值在双向链表中的位置，用于访问次数相同时排序
+type hotKeyItem struct {
+	HotKey
+	rank int
+}
+
+// 最小堆，堆顶是当前topN中最冷的键
+type hotKeyHeap []hotKeyItem
+
+// 判断a是否比b更冷
+func (h hotKeyHeap) less(a, b hotKeyItem) bool {
+	if a.Count != b.Count {
+		return a.Count < b.Count
+	}
+	return a.rank > b.rank
+}
+
+func (h hotKeyHeap) Len() int            { return len(h) }
+func (h hotKeyHeap) Less(i, j int) bool  { return h.less(h[i], h[j]) }
+func (h hotKeyHeap) Swap(i, j int)       { h[i], h[j] = h[j], h[i] }
+func (h *hotKeyHeap) Push(x interface{}) { *h = append(*h, x.(hotKeyItem)) }
+func (h *hotKeyHeap) Pop() interface{} {
+	old := *h
+	item := old[len(old)-1]
+	*h = old[:len(old)-1]
+	return item
+}
@@ -0,0 +1,52 @@
+/*
+Copyright 2013 Google Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package lru
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestHotKeys(t *testing.T) {
+	lru := New(0)
+	for i := 0; i < 10; i++ {
+		lru.Add(i, i)
+	}
+	// 键i被访问i次，键8和键9访问次数相同
+	for i := 0; i < 10; i++ {
+		n := i
+		if i == 9 {
+			n = 8
+		}
+		for j := 0; j < n; j++ {
+			lru.Get(i)
+		}
+	}
+	keys := lru.Keys()
+
+	got := fmt.Sprint(lru.HotKeys(3))
+	if want := "[{9 8} {8 8} {7 7}]"; got != want {
+		t.Fatalf("got hot keys %s; want %s", got, want)
+	}
+	// HotKeys不改变键值的位置
+	if fmt.Sprint(lru.Keys()) != fmt.Sprint(keys) {
+		t.Fatal("HotKeys changed the recency order")
+	}
+	if got := len(lru.HotKeys(100)); got != 10 {
+		t.Fatalf("got %d hot keys; want 10", got)
+	}
+}
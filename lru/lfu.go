@@ -0,0 +1,169 @@
+/*
+Copyright 2013 Google Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package lru
+
+import "container/list"
+
+// LFU是最不经常使用缓存的实现，不是并发安全的
+// 缓存已满时移除访问次数最少的键值，访问次数相同时移除最久没有使用的键值
+type LFU struct {
+	// 缓存元素的最大数量限制，0 代表没有限制
+	MaxEntries int
+
+	// 缓存元素被移除的时候触发的回调函数
+	OnEvicted func(key Key, value interface{})
+
+	// 访问次数相同的键值组成一个双向链表，最近使用的在最前面
+	freqs   map[int]*list.List
+	minFreq int
+	cache   map[interface{}]*list.Element
+}
+
+// LFU的键值对，记录访问次数
+type lfuEntry struct {
+	key   Key
+	value interface{}
+	count int
+}
+
+// LFU结构的构造函数
+func NewLFU(maxEntries int) *LFU {
+	return &LFU{
+		MaxEntries: maxEntries,
+		freqs:      make(map[int]*list.List),
+		cache:      make(map[interface{}]*list.Element),
+	}
+}
+
+// 添加键值到缓存，更新已缓存的键值会增加访问次数
+func (c *LFU) Add(key Key, value interface{}) {
+	if c.cache == nil {
+		c.freqs = make(map[int]*list.List)
+		c.cache = make(map[interface{}]*list.Element)
+	}
+
+	if ele, ok := c.cache[key]; ok {
+		ele.Value.(*lfuEntry).value = value
+		c.increment(ele)
+		return
+	}
+
+	// 如果元素个数已经达到最大限制，移除访问次数最少的键值
+	if c.MaxEntries != 0 && len(c.cache) >= c.MaxEntries {
+		c.RemoveLeastFrequent()
+	}
+	c.cache[key] = c.push(&lfuEntry{key: key, value: value, count: 1})
+	c.minFreq = 1
+}
+
+// 从缓存中获取键值，命中时增加访问次数
+func (c *LFU) Get(key Key) (value interface{}, ok bool) {
+	if c.cache == nil {
+		return
+	}
+	if ele, hit := c.cache[key]; hit {
+		kv := ele.Value.(*lfuEntry)
+		c.increment(ele)
+		return kv.value, true
+	}
+	return
+}
+
+// 增加访问次数，将键值移动到新的访问次数链表的最前面
+func (c *LFU) increment(e *list.Element) {
+	kv := c.unlink(e)
+	if kv.count == c.minFreq && c.freqs[kv.count] == nil {
+		c.minFreq++
+	}
+	kv.count++
+	c.cache[kv.key] = c.push(kv)
+}
+
+// 将键值添加到对应访问次数链表的最前面
+func (c *LFU) push(kv *lfuEntry) *list.Element {
+	l := c.freqs[kv.count]
+	if l == nil {
+		l = list.New()
+		c.freqs[kv.count] = l
+	}
+	return l.PushFront(kv)
+}
+
+// 将键值从访问次数链表中移除，链表为空时删除链表
+func (c *LFU) unlink(e *list.Element) *lfuEntry {
+	kv := e.Value.(*lfuEntry)
+	l := c.freqs[kv.count]
+	l.Remove(e)
+	if l.Len() == 0 {
+		delete(c.freqs, kv.count)
+	}
+	return kv
+}
+
+// 从缓存中移除键值
+func (c *LFU) Remove(key Key) {
+	if c.cache == nil {
+		return
+	}
+	if ele, hit := c.cache[key]; hit {
+		c.removeElement(ele)
+	}
+}
+
+// 从缓存中移除访问次数最少的键值，访问次数相同时移除最久没有使用的键值
+func (c *LFU) RemoveLeastFrequent() {
+	if len(c.cache) == 0 {
+		return
+	}
+	// Remove可能删除了minFreq对应的链表，重新查找最少的访问次数
+	if c.freqs[c.minFreq] == nil {
+		c.minFreq = 0
+		for count := range c.freqs {
+			if c.minFreq == 0 || count < c.minFreq {
+				c.minFreq = count
+			}
+		}
+	}
+	c.removeElement(c.freqs[c.minFreq].Back())
+}
+
+// 从缓存中移除键值
+func (c *LFU) removeElement(e *list.Element) {
+	kv := c.unlink(e)
+	delete(c.cache, kv.key)
+	if c.OnEvicted != nil {
+		c.OnEvicted(kv.key, kv.value)
+	}
+}
+
+// 获取缓存的元素数量
+func (c *LFU) Len() int {
+	return len(c.cache)
+}
+
+// 重置缓存，清除所有元素
+func (c *LFU) Clear() {
+	if c.OnEvicted != nil {
+		for _, e := range c.cache {
+			kv := e.Value.(*lfuEntry)
+			c.OnEvicted(kv.key, kv.value)
+		}
+	}
+	c.freqs = nil
+	c.cache = nil
+	c.minFreq = 0
+}
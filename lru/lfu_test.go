@@ -0,0 +1,88 @@
+/*
+Copyright 2013 Google Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package lru
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestLFUHotKeySurvives(t *testing.T) {
+	c := NewLFU(10)
+	c.Add("hot", 1234)
+	for i := 0; i < 100; i++ {
+		c.Get("hot")
+	}
+
+	// 插入大量新键值不会移除访问次数多的键值
+	for i := 0; i < 100; i++ {
+		c.Add(fmt.Sprintf("myKey%d", i), i)
+	}
+	if val, ok := c.Get("hot"); !ok || val != 1234 {
+		t.Fatalf("Get(hot) = %v, %v; want 1234, true", val, ok)
+	}
+	if got := c.Len(); got != 10 {
+		t.Fatalf("got %d entries; want 10", got)
+	}
+}
+
+func TestLFUTieBreakByRecency(t *testing.T) {
+	evictedKeys := make([]Key, 0)
+	c := NewLFU(3)
+	c.OnEvicted = func(key Key, value interface{}) {
+		evictedKeys = append(evictedKeys, key)
+	}
+	c.Add("myKey1", 1)
+	c.Add("myKey2", 2)
+	c.Add("myKey3", 3)
+	c.Get("myKey1")
+	c.Add("myKey2", 22)
+
+	// myKey3访问次数最少
+	c.Add("myKey4", 4)
+	// 所有键值访问次数相同，myKey1最久没有使用
+	c.Get("myKey4")
+	c.Add("myKey5", 5)
+	// myKey5访问次数最少
+	c.Add("myKey6", 6)
+
+	if got, want := fmt.Sprint(evictedKeys), "[myKey3 myKey1 myKey5]"; got != want {
+		t.Fatalf("got evicted keys %s; want %s", got, want)
+	}
+}
+
+func TestLFURemove(t *testing.T) {
+	c := NewLFU(2)
+	c.Add("myKey1", 1)
+	c.Get("myKey1")
+	c.Add("myKey2", 2)
+	c.Remove("myKey2")
+
+	// 移除访问次数最少的键值之后仍然能正确移除
+	c.Add("myKey3", 3)
+	c.Get("myKey3")
+	c.Get("myKey3")
+	c.Add("myKey4", 4)
+	if _, ok := c.Get("myKey1"); ok {
+		t.Fatal("least frequently used key was not evicted")
+	}
+
+	c.Clear()
+	if got := c.Len(); got != 0 {
+		t.Fatalf("got %d entries after Clear; want 0", got)
+	}
+}
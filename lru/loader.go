@@ -0,0 +1,171 @@
+/*
+Copyright 2013 Google Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package lru
+
+import (
+	"errors"
+	"sync"
+	"time"
+)
+
+// 调用GetOrLoad时没有设置Loader
+var ErrNoLoader = errors.New("lru: Loader is nil")
+
+// 执行加载的调用者panic时，等待同一个键的其他调用者得到的错误
+var errLoadPanic = errors.New("lru: Loader panicked")
+
+// 从缓存中获取键值，如果未命中，调用Loader加载并添加到缓存
+// Loader返回的错误不会被缓存，下一次调用会重新加载
+// 并发场景下推荐使用SafeCache.GetOrLoadDedup，避免同一个键被重复加载
+func (c *Cache) GetOrLoad(key Key) (interface{}, error) {
+	if value, ok := c.Get(key); ok {
+		return value, nil
+	}
+	if c.Loader == nil {
+		return nil, ErrNoLoader
+	}
+	value, err := c.Loader(key)
+	if err != nil {
+		return nil, err
+	}
+	c.Add(key, value)
+	return value, nil
+}
+
+// 从缓存中获取键值，如果未命中，调用Loader加载并添加到缓存
+// Loader在释放锁之后调用，加载期间其他键值可以正常访问，同一个键可能被并发加载
+func (s *SafeCache) GetOrLoad(key Key) (interface{}, error) {
+	return s.load(key, false)
+}
+
+// 和GetOrLoad相同，但是同一个键的并发加载合并为一次
+func (s *SafeCache) GetOrLoadDedup(key Key) (interface{}, error) {
+	return s.load(key, true)
+}
+
+func (s *SafeCache) load(key Key, dedup bool) (interface{}, error) {
+	s.mu.Lock()
+	value, ok := s.cache.Get(key)
+	loader := s.cache.Loader
+	s.unlock()
+	if ok {
+		return value, nil
+	}
+	if loader == nil {
+		return nil, ErrNoLoader
+	}
+
+	fn := func() (interface{}, error) {
+		value, err := loader(key)
+		if err != nil {
+			return nil, err
+		}
+		s.Add(key, value)
+		return value, nil
+	}
+	if !dedup {
+		return fn()
+	}
+	return s.loadOnce(key, fn)
+}
+
+// 正在进行的GetOrLoadDedup加载
+type loadCall struct {
+	wg    sync.WaitGroup
+	value interface{}
+	err   error
+}
+
+// 同一个键同时只执行一次fn，其他调用者等待并共享结果
+// 直接使用键本身判断是否相同，不同的键即使格式化之后相同也不会合并
+func (s *SafeCache) loadOnce(key Key, fn func() (interface{}, error)) (interface{}, error) {
+	s.mu.Lock()
+	if c, ok := s.loads[key]; ok {
+		s.mu.Unlock()
+		c.wg.Wait()
+		return c.value, c.err
+	}
+	if s.loads == nil {
+		s.loads = make(map[Key]*loadCall)
+	}
+	c := new(loadCall)
+	c.wg.Add(1)
+	s.loads[key] = c
+	s.mu.Unlock()
+
+	// fn panic时也要唤醒等待的调用者
+	c.err = errLoadPanic
+	defer func() {
+		s.mu.Lock()
+		delete(s.loads, key)
+		s.mu.Unlock()
+		c.wg.Done()
+	}()
+	c.value, c.err = fn()
+	return c.value, c.err
+}
+
+// 判断键值是否需要提前刷新，返回键值原来的存活时间和当前的版本号
+func (c *Cache) refreshDue(key Key) (ttl time.Duration, version uint64, due bool) {
+	if c.RefreshAheadFactor <= 0 || c.Loader == nil {
+		return 0, 0, false
+	}
+	kv := c.lookup(key)
+	if kv == nil || kv.expiresAt.IsZero() {
+		return 0, 0, false
+	}
+	ttl = kv.expiresAt.Sub(kv.updatedAt)
+	refreshAt := kv.updatedAt.Add(time.Duration(float64(ttl) * c.RefreshAheadFactor))
+	return ttl, kv.version, !c.timeNow().Before(refreshAt)
+}
+
+// 判断键值是否需要提前刷新，同一个键同时只有一个刷新，需要持有锁
+func (s *SafeCache) startRefresh(key Key) (ttl time.Duration, version uint64, ok bool) {
+	if _, busy := s.refreshing[key]; busy {
+		return 0, 0, false
+	}
+	ttl, version, ok = s.cache.refreshDue(key)
+	if !ok {
+		return 0, 0, false
+	}
+	if s.refreshing == nil {
+		s.refreshing = make(map[interface{}]struct{})
+	}
+	s.refreshing[key] = struct{}{}
+	return ttl, version, true
+}
+
+// 在后台调用Loader重新加载键值，加载成功时使用原来的存活时间添加到缓存
+// 加载失败时保留当前的value，直到键值过期
+// 加载期间键值被移除、过期或者重新写入时丢弃加载的结果，不会把键值加回缓存
+func (s *SafeCache) refresh(key Key, ttl time.Duration, version uint64) {
+	s.mu.Lock()
+	loader := s.cache.Loader
+	s.unlock()
+
+	value, err := loader(key)
+
+	s.mu.Lock()
+	defer s.unlock()
+	delete(s.refreshing, key)
+	if err != nil {
+		return
+	}
+	if kv := s.cache.lookup(key); kv != nil && kv.version == version {
+		s.cache.AddWithTTL(key, value, ttl)
+	}
+}
@@ -0,0 +1,230 @@
+/*
+Copyright 2013 Google Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package lru
+
+import (
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/golang/groupcache/lru/lrutest"
+)
+
+func TestGetOrLoad(t *testing.T) {
+	lru := New(0)
+	if _, err := lru.GetOrLoad("myKey"); err != ErrNoLoader {
+		t.Fatalf("got error %v; want ErrNoLoader", err)
+	}
+
+	calls := 0
+	fail := true
+	lru.Loader = func(key Key) (interface{}, error) {
+		calls++
+		if fail {
+			return nil, errors.New("load failed")
+		}
+		return 1234, nil
+	}
+
+	// 错误不会被缓存
+	if _, err := lru.GetOrLoad("myKey"); err == nil {
+		t.Fatal("GetOrLoad returned no error")
+	}
+	if lru.Len() != 0 {
+		t.Fatal("failed load was cached")
+	}
+
+	fail = false
+	for i := 0; i < 2; i++ {
+		if val, err := lru.GetOrLoad("myKey"); err != nil || val != 1234 {
+			t.Fatalf("GetOrLoad = %v, %v; want 1234, nil", val, err)
+		}
+	}
+	if calls != 2 {
+		t.Fatalf("Loader called %d times; want 2", calls)
+	}
+}
+
+// 并发加载同一个键，Loader只会执行一次
+func TestGetOrLoadDedup(t *testing.T) {
+	lru := New(0)
+	var mu sync.Mutex
+	calls := 0
+	lru.Loader = func(key Key) (interface{}, error) {
+		mu.Lock()
+		calls++
+		mu.Unlock()
+		time.Sleep(10 * time.Millisecond)
+		return 1234, nil
+	}
+	s := ThreadSafe(lru)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if val, err := s.GetOrLoadDedup("myKey"); err != nil || val != 1234 {
+				t.Errorf("GetOrLoadDedup = %v, %v; want 1234, nil", val, err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	if calls != 1 {
+		t.Fatalf("Loader called %d times; want 1", calls)
+	}
+}
+
+// 格式化之后相同的不同键不会合并加载
+func TestGetOrLoadDedupDistinctKeys(t *testing.T) {
+	type pair struct{ a, b string }
+	k1, k2 := pair{"x y", ""}, pair{"x", "y "}
+
+	lru := New(0)
+	started := make(chan struct{})
+	release := make(chan struct{})
+	lru.Loader = func(key Key) (interface{}, error) {
+		if key == k1 {
+			close(started)
+			<-release
+		}
+		return key, nil
+	}
+	s := ThreadSafe(lru)
+
+	done := make(chan interface{})
+	go func() {
+		v, _ := s.GetOrLoadDedup(k1)
+		done <- v
+	}()
+	<-started
+	if v, err := s.GetOrLoadDedup(k2); err != nil || v != k2 {
+		t.Errorf("GetOrLoadDedup(%v) = %v, %v; want %v, nil", k2, v, err, k2)
+	}
+	close(release)
+	if v := <-done; v != k1 {
+		t.Errorf("GetOrLoadDedup(%v) = %v; want %v", k1, v, k1)
+	}
+}
+
+func TestRefreshAhead(t *testing.T) {
+	lru := New(0)
+	clock := lrutest.NewFakeClock(time.Unix(0, 0))
+	lru.SetClock(clock)
+	lru.RefreshAheadFactor = 0.8
+
+	var mu sync.Mutex
+	calls := 0
+	release := make(chan struct{})
+	lru.Loader = func(key Key) (interface{}, error) {
+		mu.Lock()
+		calls++
+		mu.Unlock()
+		<-release
+		return 5678, nil
+	}
+	s := ThreadSafe(lru)
+	s.AddWithTTL("myKey", 1234, 10*time.Second)
+
+	// 存活时间没有超过80%，不会刷新
+	clock.Advance(7 * time.Second)
+	s.Get("myKey")
+
+	// 超过80%之后立即返回当前的value，同一个键只有一个刷新
+	clock.Advance(time.Second)
+	for i := 0; i < 3; i++ {
+		if val, ok := s.Get("myKey"); !ok || val != 1234 {
+			t.Fatalf("Get = %v, %v; want 1234, true", val, ok)
+		}
+	}
+	close(release)
+
+	deadline := time.Now().Add(time.Second)
+	for {
+		if val, _ := s.Peek("myKey"); val == 5678 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("entry was not refreshed in the background")
+		}
+		time.Sleep(time.Millisecond)
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	if calls != 1 {
+		t.Fatalf("Loader called %d times; want 1", calls)
+	}
+	if got, ok := s.TTLRemaining("myKey"); !ok || got != 10*time.Second {
+		t.Fatalf("TTLRemaining = %v, %v; want 10s, true", got, ok)
+	}
+}
+
+// 后台刷新期间键值被移除、过期、清空或者重新写入时，不会写入刷新的结果
+func TestRefreshAheadStale(t *testing.T) {
+	tests := []struct {
+		name   string
+		change func(s *SafeCache, clock *lrutest.FakeClock)
+		want   interface{} // 刷新完成之后Peek的结果，nil代表不存在
+	}{
+		{"Remove", func(s *SafeCache, _ *lrutest.FakeClock) { s.Remove("myKey") }, nil},
+		{"Expire", func(_ *SafeCache, clock *lrutest.FakeClock) { clock.Advance(3 * time.Second) }, nil},
+		{"Clear", func(s *SafeCache, _ *lrutest.FakeClock) { s.Clear() }, nil},
+		{"Add", func(s *SafeCache, _ *lrutest.FakeClock) { s.AddWithTTL("myKey", 9999, time.Minute) }, 9999},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			lru := New(0)
+			clock := lrutest.NewFakeClock(time.Unix(0, 0))
+			lru.SetClock(clock)
+			lru.RefreshAheadFactor = 0.8
+			started := make(chan struct{})
+			release := make(chan struct{})
+			lru.Loader = func(key Key) (interface{}, error) {
+				close(started)
+				<-release
+				return 5678, nil
+			}
+			s := ThreadSafe(lru)
+			s.AddWithTTL("myKey", 1234, 10*time.Second)
+
+			clock.Advance(8 * time.Second)
+			s.Get("myKey")
+			<-started
+			tt.change(s, clock)
+			close(release)
+
+			deadline := time.Now().Add(time.Second)
+			for {
+				s.mu.Lock()
+				busy := len(s.refreshing) > 0
+				s.mu.Unlock()
+				if !busy {
+					break
+				}
+				if time.Now().After(deadline) {
+					t.Fatal("background refresh did not finish")
+				}
+				time.Sleep(time.Millisecond)
+			}
+			if val, _ := s.Peek("myKey"); val != tt.want {
+				t.Fatalf("Peek = %v; want %v", val, tt.want)
+			}
+		})
+	}
+}
@@ -16,105 +16,819 @@ limitations under the License.
 
 package lru
 
-import "container/list"
+import (
+	"container/list"
+	"fmt"
+	"strings"
+	"time"
+)
 
 // Cache是LRU缓存的实现，不是并发安全的
 type Cache struct {
 	// 缓存元素的最大数量限制，0 代表没有限制
 	MaxEntries int
 
+	// 缓存元素占用字节数的最大限制，0 代表没有限制
+	// 需要配合Sizer使用，和MaxEntries同时生效，任意一个超出限制就触发移除
+	MaxBytes int64
+
+	// 计算缓存元素占用的字节数，为nil时所有元素占用0字节
+	Sizer func(key Key, value interface{}) int64
+
+	// 单个缓存元素占用字节数的最大限制，0 代表没有限制
+	// 需要配合Sizer使用，超出限制的键值不会被添加，也不会移除已缓存的键值
+	MaxValueBytes int64
+
+	// 键值因为超出MaxValueBytes被拒绝添加的时候触发的回调函数
+	OnRejected func(key Key, size int64)
+
+	// 缓存元素权重总和的最大限制，0 代表没有限制
+	// 权重由AddWithCost的调用者指定，和MaxEntries、MaxBytes同时生效
+	MaxCost int64
+
+	// 键值的最大存活时间，从添加时间开始计算，0 代表没有限制
+	// 和AddWithTTL指定的过期时间同时生效，超过任意一个都视为过期
+	MaxAge time.Duration
+
+	// 选择被移除键值的策略，为nil时移除最近没有使用的键值
+	Policy EvictionPolicy
+
+	// 移除模式，默认是ModeLRU，设置了Policy时只影响访问是否移动键值
+	Mode EvictMode
+
+	// 未命中时加载键值的函数，供GetOrLoad使用
+	Loader func(key Key) (interface{}, error)
+
+	// 提前刷新的比例，例如0.8，0 代表不提前刷新
+	// 通过SafeCache.Get命中的带有过期时间的键值，存活时间超过这个比例时，在后台调用Loader重新加载
+	// 只对SafeCache生效，因为后台加载需要并发安全
+	RefreshAheadFactor float64
+
 	// 缓存元素被移除的时候触发的回调函数
 	OnEvicted func(key Key, value interface{})
 
+	// 缓存元素被移除的时候触发的回调函数，带有移除的原因
+	// 设置之后代替OnEvicted被调用
+	OnEvictedWithReason func(key Key, value interface{}, reason EvictReason)
+
 	// 缓存元素存储的数据结构：双向链表+哈希表
 	ll    *list.List
 	cache map[interface{}]*list.Element
+
+	// 所有缓存元素占用的字节数
+	nbytes int64
+
+	// 所有缓存元素的权重总和
+	ncost int64
+
+	// 缓存命中、未命中和移除的统计
+	stats Stats
+
+	// 冻结期间超出最大限制也不会移除键值
+	frozen bool
+
+	// 快速判断键一定不存在的布隆过滤器，为nil时不使用
+	bloom *bloomFilter
+
+	// 最近一次分配的版本号，键值被移除再添加之后版本号也不会重复
+	version uint64
+
+	// 获取当前时间，为nil时使用系统时间，通过SetClock设置
+	clock Clock
+
+	// 异步通知被移除键值的通道，为nil时不发送
+	evictCh       chan Evicted
+	evictOverflow EvictOverflow
+}
+
+// 缓存的统计数据
+type Stats struct {
+	Hits       int64 // Get命中的次数
+	Misses     int64 // Get未命中的次数
+	Evictions  int64 // 超出容量限制移除的次数
+	Rejections int64 // 超出MaxValueBytes拒绝添加的次数
+	EvictDrops int64 // 移除通道已满丢弃的事件数量
+}
+
+// Clock是缓存获取当前时间的接口，过期时间、MaxAge、访问时间和提前刷新都使用Clock
+// 测试时可以使用lrutest.FakeClock手动调整时间
+type Clock interface {
+	Now() time.Time
+}
+
+// RealClock是使用系统时间的Clock，是Cache默认使用的Clock
+type RealClock struct{}
+
+// 获取系统的当前时间
+func (RealClock) Now() time.Time {
+	return time.Now()
+}
+
+// 缓存的移除模式
+type EvictMode int
+
+const (
+	ModeLRU  EvictMode = iota // 访问时移动到最前面，移除最久未使用的键值
+	ModeFIFO                  // 访问时不移动，移除最早添加的键值
+	ModeMRU                   // 访问时移动到最前面，移除最近使用的键值
+)
+
+// 缓存元素被移除的原因
+type EvictReason int
+
+const (
+	ReasonCapacity EvictReason = iota // 超出容量限制
+	ReasonManual                      // 调用者主动移除
+	ReasonExpired                     // 超过存活时间
+	ReasonClear                       // 重置缓存
+	ReasonMaxAge                      // 超过MaxAge
+)
+
+func (r EvictReason) String() string {
+	switch r {
+	case ReasonCapacity:
+		return "capacity"
+	case ReasonManual:
+		return "manual"
+	case ReasonExpired:
+		return "expired"
+	case ReasonClear:
+		return "clear"
+	case ReasonMaxAge:
+		return "max-age"
+	}
+	return "unknown"
 }
 
 // 键值可以是任何可比较的数据类型
 type Key interface{}
 
+// AddMulti批量添加的键值对
+type Entry struct {
+	Key   Key
+	Value interface{}
+}
+
 // 键值对的数据结构，存储到哈希表
 type entry struct {
 	key   Key
 	value interface{}
+
+	// 过期时间，零值代表永不过期
+	expiresAt time.Time
+
+	// 添加时通过Sizer计算的字节数
+	size int64
+
+	// 添加时由调用者指定的权重
+	cost int64
+
+	// 固定的键值不会因为超出最大限制被移除
+	pinned bool
+
+	// 添加的时间、最近一次访问的时间和最近一次通过Add写入的时间
+	createdAt  time.Time
+	accessedAt time.Time
+	updatedAt  time.Time
+
+	// 每次写入value之后递增的版本号，用于CompareAndSwap
+	version uint64
+
+	// 添加之后被访问的次数
+	hits int64
 }
 
+// 预分配哈希表的最大容量，避免MaxEntries很大时一次分配过多内存
+const maxPrealloc = 1 << 20
+
 // Cache结构的构造函数
+// maxEntries大于0时按照maxEntries预分配哈希表，避免预热期间反复扩容
 func New(maxEntries int) *Cache {
+	return NewWithCapacity(maxEntries, maxEntries)
+}
+
+// Cache结构的构造函数，按照hint预分配哈希表
+// 适用于maxEntries为0（没有限制）但是可以预估元素数量的场景
+func NewWithCapacity(maxEntries, hint int) *Cache {
+	if hint < 0 {
+		hint = 0
+	}
+	if hint > maxPrealloc {
+		hint = maxPrealloc
+	}
 	return &Cache{
 		MaxEntries: maxEntries,
 		ll:         list.New(),
-		cache:      make(map[interface{}]*list.Element),
+		cache:      make(map[interface{}]*list.Element, hint),
+	}
+}
+
+// 添加键值到缓存，键值永不过期
+// 如果添加导致超出最大限制而移除了键值，返回第一个被移除的键，evicted为true
+// 只有MaxEntries限制时，更新已缓存的键值不会移除键值
+func (c *Cache) Add(key Key, value interface{}) (evictedKey Key, evicted bool) {
+	evictedKey, evicted, _ = c.add(key, value, time.Time{}, 0)
+	return
+}
+
+// 添加键值到缓存，键值在ttl之后过期
+// 过期的键值在Get的时候视为未命中并被移除
+func (c *Cache) AddWithTTL(key Key, value interface{}, ttl time.Duration) (evictedKey Key, evicted bool) {
+	evictedKey, evicted, _ = c.add(key, value, c.timeNow().Add(ttl), 0)
+	return
+}
+
+// 添加键值到缓存，并指定键值的权重
+// 权重总和超出MaxCost时，移除最近没有使用的键值
+func (c *Cache) AddWithCost(key Key, value interface{}, cost int64) (evictedKey Key, evicted bool) {
+	evictedKey, evicted, _ = c.add(key, value, time.Time{}, cost)
+	return
+}
+
+// 添加或者替换键值，返回替换之前的value，和Add一样将键值移动到双向链表的最前面
+// 键值不存在或者已过期时添加键值，返回nil和false
+func (c *Cache) Replace(key Key, value interface{}) (old interface{}, existed bool) {
+	if kv := c.lookup(key); kv != nil {
+		old, existed = kv.value, true
+	}
+	c.Add(key, value)
+	return old, existed
+}
+
+// 添加键值到缓存，返回键值是否被添加
+// 超出MaxValueBytes的键值被拒绝时返回false，Add也会拒绝这样的键值，但是不返回结果
+func (c *Cache) TryAdd(key Key, value interface{}) bool {
+	_, _, stored := c.add(key, value, time.Time{}, 0)
+	return stored
+}
+
+func (c *Cache) add(key Key, value interface{}, expiresAt time.Time, cost int64) (evictedKey Key, evicted, stored bool) {
+	ele := c.set(key, value, expiresAt, cost)
+	if ele == nil {
+		return
 	}
+	// 如果元素个数、字节数或者权重超出最大限制，移除最近没有使用的键值
+	n, evictedKey := c.trim(ele)
+	return evictedKey, n > 0, true
 }
 
-// 添加键值到缓存
-func (c *Cache) Add(key Key, value interface{}) {
+// 添加或者更新键值，并移动到双向链表的最前面，不检查最大限制
+// value超出MaxValueBytes被拒绝时返回nil
+func (c *Cache) set(key Key, value interface{}, expiresAt time.Time, cost int64) *list.Element {
 	if c.cache == nil {
 		c.cache = make(map[interface{}]*list.Element)
 		c.ll = list.New()
 	}
 
-	// 如果键值已缓存，将元素移动到双向链表的最前面，更新value
-	if ee, ok := c.cache[key]; ok {
-		c.ll.MoveToFront(ee)
-		ee.Value.(*entry).value = value
-		return
+	var size int64
+	if c.Sizer != nil {
+		size = c.Sizer(key, value)
+		if c.reject(key, size) {
+			return nil
+		}
+	}
+
+	ele, ok := c.cache[key]
+	if ok {
+		// 如果键值已缓存，将元素移动到双向链表的最前面，更新value、字节数和权重
+		c.promote(ele)
+		kv := ele.Value.(*entry)
+		kv.value = value
+		kv.expiresAt = expiresAt
+		kv.updatedAt = c.timeNow()
+		kv.version = c.nextVersion()
+		c.nbytes += size - kv.size
+		kv.size = size
+		c.ncost += cost - kv.cost
+		kv.cost = cost
+	} else {
+		// 如果键值未缓存，将元素添加到双向链表的最前面
+		ele = c.insert(&entry{key: key, value: value, expiresAt: expiresAt, size: size, cost: cost})
+	}
+	return ele
+}
+
+// 批量添加键值，entries中靠后的键值位于双向链表的更前面
+// 全部添加之后只检查一次最大限制，返回移除的数量
+// 同一个键出现多次时只保留最后一个value
+func (c *Cache) AddMulti(entries []Entry) (evicted int) {
+	for _, e := range entries {
+		c.set(e.Key, e.Value, time.Time{}, 0)
+	}
+	evicted, _ = c.trim(nil)
+	return evicted
+}
+
+// 将新的键值添加到双向链表的最前面，不检查最大限制
+func (c *Cache) insert(kv *entry) *list.Element {
+	kv.createdAt = c.timeNow()
+	kv.accessedAt = kv.createdAt
+	kv.updatedAt = kv.createdAt
+	kv.version = c.nextVersion()
+	ele := c.ll.PushFront(kv)
+	c.cache[kv.key] = ele
+	c.nbytes += kv.size
+	c.ncost += kv.cost
+	if c.Policy != nil {
+		c.Policy.Add(kv.key)
+	}
+	if c.bloom != nil {
+		c.bloomAdd(kv.key)
 	}
+	return ele
+}
+
+// 判断键值是否超出MaxValueBytes，超出时触发OnRejected
+func (c *Cache) reject(key Key, size int64) bool {
+	if c.MaxValueBytes == 0 || size <= c.MaxValueBytes {
+		return false
+	}
+	c.stats.Rejections++
+	if c.OnRejected != nil {
+		c.OnRejected(key, size)
+	}
+	return true
+}
 
-	// 如果键值未缓存，将元素添加到双向链表的最前面
-	ele := c.ll.PushFront(&entry{key, value})
-	c.cache[key] = ele
-	if c.MaxEntries != 0 && c.ll.Len() > c.MaxEntries {
-		// 如果元素个数已经达到最大限制，移除最近没有使用的键值
-		c.RemoveOldest()
+// 移除最近没有使用的键值，直到不超出最大限制，返回移除的数量和第一个被移除的键
+// keep是正在添加或者更新的元素，不会被移除
+func (c *Cache) trim(keep *list.Element) (n int, first Key) {
+	if c.frozen || c.cache == nil {
+		return 0, nil
 	}
+	for c.overflow() {
+		key, _, ok := c.removeOldest(ReasonCapacity, keep)
+		if !ok {
+			// 剩下的键值都是固定的，允许超出最大限制
+			break
+		}
+		if n == 0 {
+			first = key
+		}
+		n++
+	}
+	return n, first
+}
+
+// 判断元素个数、字节数或者权重是否超出最大限制
+func (c *Cache) overflow() bool {
+	if c.ll.Len() == 0 {
+		return false
+	}
+	return (c.MaxEntries != 0 && c.ll.Len() > c.MaxEntries) ||
+		(c.MaxBytes != 0 && c.nbytes > c.MaxBytes) ||
+		(c.MaxCost != 0 && c.ncost > c.MaxCost)
 }
 
 // 从缓存中获取键值
 func (c *Cache) Get(key Key) (value interface{}, ok bool) {
-	if c.cache == nil {
+	if c.cache == nil || c.definitelyAbsent(key) {
+		c.stats.Misses++
 		return
 	}
 	if ele, hit := c.cache[key]; hit {
+		// 如果键值已过期，移除键值，视为未命中
+		if c.expired(ele.Value.(*entry)) {
+			c.removeElement(ele, c.expireReason(ele.Value.(*entry)))
+			c.stats.Misses++
+			return
+		}
 		// 如果键值已缓存，将元素移动到双向链表的最前面，返回value
-		c.ll.MoveToFront(ele)
+		c.promote(ele)
+		c.stats.Hits++
 		return ele.Value.(*entry).value, true
 	}
+	c.stats.Misses++
+	return
+}
+
+// 从缓存中获取键值和版本号，和Get一样将键值移动到双向链表的最前面
+// 每次写入value之后版本号都会改变，可以配合CompareAndSwap检测并发修改
+func (c *Cache) GetWithVersion(key Key) (value interface{}, version uint64, ok bool) {
+	if value, ok = c.Get(key); !ok {
+		return nil, 0, false
+	}
+	return value, c.cache[key].Value.(*entry).version, true
+}
+
+// 只有键值的版本号等于expectedVersion时才更新value，成功时版本号递增
+// 和Update一样不会改变键值的位置，键值不存在、已过期或者版本号不一致时返回false
+func (c *Cache) CompareAndSwap(key Key, expectedVersion uint64, newValue interface{}) bool {
+	kv := c.lookup(key)
+	if kv == nil || kv.version != expectedVersion {
+		return false
+	}
+	return c.Update(key, newValue)
+}
+
+// 从缓存中获取键值，已过期的键值也会返回，expired为true，不会被移除
+// 命中时将元素移动到双向链表的最前面，可以用于先返回旧值再异步刷新
+func (c *Cache) GetStale(key Key) (value interface{}, expired bool, ok bool) {
+	if c.cache == nil {
+		return
+	}
+	ele, hit := c.cache[key]
+	if !hit {
+		return
+	}
+	c.promote(ele)
+	kv := ele.Value.(*entry)
+	return kv.value, c.expired(kv), true
+}
+
+// 将已缓存的键值移动到双向链表的最前面，不获取value
+// 键值不存在或者已过期时返回false
+func (c *Cache) Touch(key Key) bool {
+	if c.cache == nil {
+		return false
+	}
+	ele, hit := c.cache[key]
+	if !hit || c.expired(ele.Value.(*entry)) {
+		return false
+	}
+	c.promote(ele)
+	return true
+}
+
+// 将元素移动到双向链表的最前面（ModeFIFO时不移动），并通知移除策略键值被访问
+func (c *Cache) promote(e *list.Element) {
+	if c.Mode != ModeFIFO {
+		c.ll.MoveToFront(e)
+	}
+	kv := e.Value.(*entry)
+	kv.accessedAt = c.timeNow()
+	kv.hits++
+	if c.Policy != nil {
+		c.Policy.Access(kv.key)
+	}
+}
+
+// 更新已缓存键值的value，不会将元素移动到双向链表的最前面
+// 键值不存在、已过期或者value超出MaxValueBytes时返回false，不会添加键值
+func (c *Cache) Update(key Key, value interface{}) bool {
+	if c.cache == nil {
+		return false
+	}
+	ele, hit := c.cache[key]
+	if !hit {
+		return false
+	}
+	kv := ele.Value.(*entry)
+	if c.expired(kv) {
+		return false
+	}
+	if c.Sizer == nil {
+		kv.value = value
+		kv.version = c.nextVersion()
+		return true
+	}
+	size := c.Sizer(key, value)
+	if c.reject(key, size) {
+		return false
+	}
+	kv.value = value
+	kv.version = c.nextVersion()
+	c.nbytes += size - kv.size
+	kv.size = size
+	c.trim(ele)
+	return true
+}
+
+// 从缓存中获取键值，如果未命中，调用compute计算value并添加到缓存
+// 命中时loaded为true，compute只会在未命中时调用
+func (c *Cache) GetOrAdd(key Key, compute func() interface{}) (value interface{}, loaded bool) {
+	if value, ok := c.Get(key); ok {
+		return value, true
+	}
+	value = compute()
+	c.Add(key, value)
+	return value, false
+}
+
+// 键值不存在时添加键值，返回value和true
+// 键值已存在时不覆盖，将键值移动到双向链表的最前面，返回已缓存的value和false
+// 和GetOrAdd不同，调用者已经有value，不需要计算
+func (c *Cache) AddIfAbsent(key Key, value interface{}) (actual interface{}, inserted bool) {
+	if actual, ok := c.Get(key); ok {
+		return actual, false
+	}
+	c.Add(key, value)
+	return value, true
+}
+
+// 批量获取键值，values和found与keys的顺序一一对应，未命中时value为nil
+// 命中的键值依次移动到双向链表的最前面
+func (c *Cache) GetMulti(keys []Key) (values []interface{}, found []bool) {
+	values = make([]interface{}, len(keys))
+	found = make([]bool, len(keys))
+	for i, key := range keys {
+		values[i], found[i] = c.Get(key)
+	}
+	return values, found
+}
+
+// 从缓存中获取键值，不会将元素移动到双向链表的最前面
+// 已过期的键值视为未命中，但是不会被移除
+func (c *Cache) Peek(key Key) (value interface{}, ok bool) {
+	if c.cache == nil || c.definitelyAbsent(key) {
+		return
+	}
+	if ele, hit := c.cache[key]; hit {
+		kv := ele.Value.(*entry)
+		if c.expired(kv) {
+			return
+		}
+		return kv.value, true
+	}
 	return
 }
 
+// 判断键值是否已缓存，不会改变键值的位置，已过期的键值返回false
+func (c *Cache) Contains(key Key) bool {
+	if c == nil || c.cache == nil || c.definitelyAbsent(key) {
+		return false
+	}
+	ele, hit := c.cache[key]
+	return hit && !c.expired(ele.Value.(*entry))
+}
+
+// 获取键值剩余的存活时间，同时考虑MaxAge，不会改变键值的位置
+// 永不过期的键值返回0和true，不存在或者已过期的键值返回false
+func (c *Cache) TTLRemaining(key Key) (time.Duration, bool) {
+	if c.cache == nil {
+		return 0, false
+	}
+	ele, hit := c.cache[key]
+	if !hit {
+		return 0, false
+	}
+	kv := ele.Value.(*entry)
+	deadline := c.deadline(kv)
+	if deadline.IsZero() {
+		return 0, true
+	}
+	remaining := deadline.Sub(c.timeNow())
+	if remaining <= 0 {
+		return 0, false
+	}
+	return remaining, true
+}
+
+// 获取键值最近一次被访问的时间，Get、Touch和更新键值都会刷新访问时间
+// 键值不存在或者已过期时返回false
+func (c *Cache) LastAccessed(key Key) (time.Time, bool) {
+	kv := c.lookup(key)
+	if kv == nil {
+		return time.Time{}, false
+	}
+	return kv.accessedAt, true
+}
+
+// 获取键值被添加的时间，更新已缓存的键值不会改变添加时间
+// 键值不存在或者已过期时返回false
+func (c *Cache) CreatedAt(key Key) (time.Time, bool) {
+	kv := c.lookup(key)
+	if kv == nil {
+		return time.Time{}, false
+	}
+	return kv.createdAt, true
+}
+
+// 查找没有过期的键值，不改变键值的位置
+func (c *Cache) lookup(key Key) *entry {
+	if c.cache == nil {
+		return nil
+	}
+	ele, hit := c.cache[key]
+	if !hit || c.expired(ele.Value.(*entry)) {
+		return nil
+	}
+	return ele.Value.(*entry)
+}
+
 // 从缓存中移除键值
 func (c *Cache) Remove(key Key) {
 	if c.cache == nil {
 		return
 	}
 	if ele, hit := c.cache[key]; hit {
-		c.removeElement(ele)
+		c.removeElement(ele, ReasonManual)
+	}
+}
+
+// 从缓存中移除最老的没有固定的键值，返回被移除的键值
+// 缓存为空或者所有键值都被固定时ok为false
+func (c *Cache) RemoveOldest() (key Key, value interface{}, ok bool) {
+	return c.removeOldest(ReasonManual, nil)
+}
+
+// 移除最老的没有固定的键值，跳过keep
+func (c *Cache) removeOldest(reason EvictReason, keep *list.Element) (key Key, value interface{}, ok bool) {
+	ele := c.victim(keep)
+	if ele == nil {
+		return
+	}
+	c.removeElement(ele, reason)
+	kv := ele.Value.(*entry)
+	return kv.key, kv.value, true
+}
+
+// 选择下一个被移除的元素，跳过固定的键值和keep，没有可移除的元素时返回nil
+// 设置了Policy时由Policy选择，Policy选择的键值被固定时不移除任何元素
+// ModeMRU从双向链表的最前面开始选择，其他模式从最后面开始选择
+func (c *Cache) victim(keep *list.Element) *list.Element {
+	if c.cache == nil {
+		return nil
+	}
+
+	if c.Policy != nil {
+		key, ok := c.Policy.Victim()
+		if !ok {
+			return nil
+		}
+		if ele := c.cache[key]; ele != nil && ele != keep && !ele.Value.(*entry).pinned {
+			return ele
+		}
+		// 策略选中了需要保留或者固定的键值，按照双向链表的顺序选择其他键值，避免超出最大限制
+	}
+
+	if c.Mode == ModeMRU {
+		for ele := c.ll.Front(); ele != nil; ele = ele.Next() {
+			if ele != keep && !ele.Value.(*entry).pinned {
+				return ele
+			}
+		}
+		return nil
+	}
+	for ele := c.ll.Back(); ele != nil; ele = ele.Prev() {
+		if ele != keep && !ele.Value.(*entry).pinned {
+			return ele
+		}
+	}
+	return nil
+}
+
+// 移除并返回最老的没有固定的键值，触发回调函数，移除的原因是ReasonManual
+// 缓存为空或者所有键值都被固定时ok为false
+func (c *Cache) Pop() (key Key, value interface{}, ok bool) {
+	return c.removeOldest(ReasonManual, nil)
+}
+
+// 移除并返回最老的没有固定的键值，和Pop一样，但是不触发回调函数
+func (c *Cache) PopSilent() (key Key, value interface{}, ok bool) {
+	ele := c.victim(nil)
+	if ele == nil {
+		return
+	}
+	kv := c.unlink(ele)
+	return kv.key, kv.value, true
+}
+
+// 固定已缓存的键值，固定的键值不会因为超出最大限制被移除
+// 其他没有固定的键值都被移除之后，添加键值允许超出最大限制
+// 固定的键值仍然可以通过Remove移除
+func (c *Cache) Pin(key Key) {
+	c.setPinned(key, true)
+}
+
+// 取消固定键值，超出的最大限制在下次添加键值时恢复
+func (c *Cache) Unpin(key Key) {
+	c.setPinned(key, false)
+}
+
+func (c *Cache) setPinned(key Key, pinned bool) {
+	if c.cache == nil {
+		return
+	}
+	if ele, hit := c.cache[key]; hit {
+		ele.Value.(*entry).pinned = pinned
 	}
 }
 
-// 从缓存中移除最老的键值
-func (c *Cache) RemoveOldest() {
+// 获取缓存中最老的键值，不会移除键值也不会改变键值的位置
+func (c *Cache) GetOldest() (key Key, value interface{}, ok bool) {
 	if c.cache == nil {
 		return
 	}
 
 	ele := c.ll.Back()
 	if ele != nil {
-		c.removeElement(ele)
+		kv := ele.Value.(*entry)
+		return kv.key, kv.value, true
+	}
+	return
+}
+
+// 从缓存中移除键值，reason是移除的原因
+func (c *Cache) removeElement(e *list.Element, reason EvictReason) {
+	kv := c.unlink(e)
+	if reason == ReasonCapacity {
+		c.stats.Evictions++
 	}
+	c.evicted(kv, reason)
 }
 
-// 从缓存中移除键值
-func (c *Cache) removeElement(e *list.Element) {
+// 从双向链表和哈希表中删除元素，不触发回调函数
+func (c *Cache) unlink(e *list.Element) *entry {
 	c.ll.Remove(e)
 	kv := e.Value.(*entry)
 	delete(c.cache, kv.key)
-	if c.OnEvicted != nil {
+	c.nbytes -= kv.size
+	c.ncost -= kv.cost
+	if c.Policy != nil {
+		c.Policy.Remove(kv.key)
+	}
+	return kv
+}
+
+// 触发缓存元素被移除的回调函数
+func (c *Cache) evicted(kv *entry, reason EvictReason) {
+	if c.OnEvictedWithReason != nil {
+		c.OnEvictedWithReason(kv.key, kv.value, reason)
+	} else if c.OnEvicted != nil {
 		c.OnEvicted(kv.key, kv.value)
 	}
+	if c.evictCh != nil {
+		c.sendEvicted(Evicted{kv.key, kv.value, reason})
+	}
+}
+
+// 移除所有已过期的键值，包括超过MaxAge的键值，返回移除的数量
+func (c *Cache) PurgeExpired() int {
+	if c.cache == nil {
+		return 0
+	}
+	n := 0
+	for e := c.ll.Front(); e != nil; {
+		next := e.Next()
+		if kv := e.Value.(*entry); c.expired(kv) {
+			c.removeElement(e, c.expireReason(kv))
+			n++
+		}
+		e = next
+	}
+	return n
+}
+
+// 移除所有添加时间距今达到d的键值，不考虑键值的位置，返回移除的数量
+func (c *Cache) PurgeOlderThan(d time.Duration) int {
+	if c.cache == nil {
+		return 0
+	}
+	now := c.timeNow()
+	n := 0
+	for e := c.ll.Front(); e != nil; {
+		next := e.Next()
+		if now.Sub(e.Value.(*entry).createdAt) >= d {
+			c.removeElement(e, ReasonMaxAge)
+			n++
+		}
+		e = next
+	}
+	return n
+}
+
+// 移除所有pred返回true的键值，返回移除的数量
+// 先遍历所有键值调用pred，再统一移除，pred不能修改缓存
+func (c *Cache) RemoveFunc(pred func(key Key, value interface{}) bool) int {
+	if c.cache == nil {
+		return 0
+	}
+	var matched []*list.Element
+	for e := c.ll.Front(); e != nil; e = e.Next() {
+		kv := e.Value.(*entry)
+		if pred(kv.key, kv.value) {
+			matched = append(matched, e)
+		}
+	}
+	for _, e := range matched {
+		c.removeElement(e, ReasonManual)
+	}
+	return len(matched)
+}
+
+// 冻结缓存，冻结期间添加键值不会因为超出最大限制移除键值，允许暂时超出限制
+// 适合批量替换键值时，避免移除马上会被重新添加的键值
+func (c *Cache) Freeze() {
+	c.frozen = true
+}
+
+// 解除冻结，一次性移除超出最大限制的键值，返回移除的数量
+func (c *Cache) Unfreeze() int {
+	c.frozen = false
+	n, _ := c.trim(nil)
+	return n
+}
+
+// 修改缓存元素的最大数量限制，0 代表没有限制
+// 如果元素个数超出新的限制，移除最近没有使用的键值，返回移除的数量
+func (c *Cache) Resize(maxEntries int) int {
+	c.MaxEntries = maxEntries
+	if c.cache == nil {
+		return 0
+	}
+	n, _ := c.trim(nil)
+	return n
 }
 
 // 获取缓存的元素数量
@@ -125,14 +839,205 @@ func (c *Cache) Len() int {
 	return c.ll.Len()
 }
 
+// 按照当前的元素数量重建哈希表，释放大量键值被移除之后哈希表多占用的内存
+// Go的哈希表删除元素之后不会缩小，Shrink的时间复杂度是O(n)
+// 适合在批量移除之后调用，不要在频繁访问的路径上调用
+// 双向链表的元素和顺序保持不变
+func (c *Cache) Shrink() {
+	if c.cache == nil {
+		return
+	}
+	cache := make(map[interface{}]*list.Element, len(c.cache))
+	for k, e := range c.cache {
+		cache[k] = e
+	}
+	c.cache = cache
+}
+
+// 获取缓存元素的最大数量，0 代表没有限制
+func (c *Cache) Cap() int {
+	return c.MaxEntries
+}
+
+// 获取元素数量占最大数量的比例，没有限制时返回0
+func (c *Cache) Utilization() float64 {
+	if c.MaxEntries == 0 {
+		return 0
+	}
+	return float64(c.Len()) / float64(c.MaxEntries)
+}
+
+// 按照最近使用到最久未使用的顺序返回所有键值，不会改变键值的位置
+// 返回的切片是快照，之后修改缓存不会影响切片
+func (c *Cache) Keys() []Key {
+	if c.cache == nil {
+		return nil
+	}
+	keys := make([]Key, 0, c.ll.Len())
+	for e := c.ll.Front(); e != nil; e = e.Next() {
+		keys = append(keys, e.Value.(*entry).key)
+	}
+	return keys
+}
+
+// 按照最久未使用到最近使用的顺序返回所有键值，和Keys的顺序相反
+func (c *Cache) KeysReversed() []Key {
+	if c.cache == nil {
+		return nil
+	}
+	keys := make([]Key, 0, c.ll.Len())
+	for e := c.ll.Back(); e != nil; e = e.Prev() {
+		keys = append(keys, e.Value.(*entry).key)
+	}
+	return keys
+}
+
+// 按照最近使用到最久未使用的顺序遍历所有键值，f返回false时停止遍历
+// Range不会改变键值的位置，在f中修改缓存是不安全的
+func (c *Cache) Range(f func(key Key, value interface{}) bool) {
+	if c.cache == nil {
+		return
+	}
+	for e := c.ll.Front(); e != nil; e = e.Next() {
+		kv := e.Value.(*entry)
+		if !f(kv.key, kv.value) {
+			return
+		}
+	}
+}
+
+// String最多输出的键值数量
+const maxStringEntries = 20
+
+// 按照最近使用到最久未使用的顺序输出键值，用于调试，不会改变键值的位置
+// 格式为lru.Cache{len=2 max=10: k1=v1 k2=v2}，超过20个键值时省略剩下的键值
+func (c *Cache) String() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "lru.Cache{len=%d max=%d", c.Len(), c.MaxEntries)
+	if c.Len() > 0 {
+		b.WriteString(":")
+		n := 0
+		for e := c.ll.Front(); e != nil; e = e.Next() {
+			if n == maxStringEntries {
+				fmt.Fprintf(&b, " ...(%d more)", c.ll.Len()-n)
+				break
+			}
+			kv := e.Value.(*entry)
+			fmt.Fprintf(&b, " %v=%v", kv.key, kv.value)
+			n++
+		}
+	}
+	b.WriteString("}")
+	return b.String()
+}
+
+// 获取缓存元素占用的字节数
+func (c *Cache) Bytes() int64 {
+	return c.nbytes
+}
+
+// 获取缓存元素的权重总和
+func (c *Cache) Cost() int64 {
+	return c.ncost
+}
+
+// 获取缓存统计数据的副本
+func (c *Cache) Stats() Stats {
+	return c.stats
+}
+
+// 将缓存统计数据清零
+func (c *Cache) ResetStats() {
+	c.stats = Stats{}
+}
+
+// 复制缓存，返回的缓存拥有相同的配置和键值，键值的顺序保持不变
+// 哈希表和双向链表是新创建的，修改其中一个缓存不会影响另一个
+// value和回调函数按引用复制，Policy通过EvictionPolicy.Clone复制
+// 移除通道不会被复制
+func (c *Cache) Clone() *Cache {
+	clone := *c
+	clone.evictCh = nil
+	if c.Policy != nil {
+		clone.Policy = c.Policy.Clone()
+	}
+	if c.bloom != nil {
+		clone.bloom = c.bloom.clone()
+	}
+	if c.cache == nil {
+		return &clone
+	}
+	clone.ll = list.New()
+	clone.cache = make(map[interface{}]*list.Element, len(c.cache))
+	for e := c.ll.Front(); e != nil; e = e.Next() {
+		kv := *e.Value.(*entry)
+		clone.cache[kv.key] = clone.ll.PushBack(&kv)
+	}
+	return &clone
+}
+
 // 重置缓存，清除所有元素
 func (c *Cache) Clear() {
-	if c.OnEvicted != nil {
+	if c.OnEvicted != nil || c.OnEvictedWithReason != nil || c.evictCh != nil {
 		for _, e := range c.cache {
-			kv := e.Value.(*entry)
-			c.OnEvicted(kv.key, kv.value)
+			c.evicted(e.Value.(*entry), ReasonClear)
 		}
 	}
 	c.ll = nil
 	c.cache = nil
+	c.nbytes = 0
+	c.ncost = 0
+	if c.Policy != nil {
+		c.Policy.Clear()
+	}
+	if c.bloom != nil {
+		c.bloom.reset()
+	}
+}
+
+// 判断键值是否已过期
+func (c *Cache) expired(kv *entry) bool {
+	deadline := c.deadline(kv)
+	return !deadline.IsZero() && !c.timeNow().Before(deadline)
+}
+
+// 获取键值的过期时间，取AddWithTTL指定的过期时间和MaxAge中较早的一个
+// 零值代表永不过期
+func (c *Cache) deadline(kv *entry) time.Time {
+	if c.MaxAge == 0 {
+		return kv.expiresAt
+	}
+	maxAge := kv.createdAt.Add(c.MaxAge)
+	if kv.expiresAt.IsZero() || maxAge.Before(kv.expiresAt) {
+		return maxAge
+	}
+	return kv.expiresAt
+}
+
+// 获取已过期键值被移除的原因
+func (c *Cache) expireReason(kv *entry) EvictReason {
+	if !kv.expiresAt.IsZero() && !c.timeNow().Before(kv.expiresAt) {
+		return ReasonExpired
+	}
+	return ReasonMaxAge
+}
+
+// 分配一个新的版本号
+func (c *Cache) nextVersion() uint64 {
+	c.version++
+	return c.version
+}
+
+// 设置获取当前时间的Clock，为nil时使用系统时间
+// 已缓存键值的过期时间不会改变，需要在添加键值之前设置
+func (c *Cache) SetClock(clock Clock) {
+	c.clock = clock
+}
+
+// 获取当前时间
+func (c *Cache) timeNow() time.Time {
+	if c.clock != nil {
+		return c.clock.Now()
+	}
+	return RealClock{}.Now()
 }
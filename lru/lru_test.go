@@ -18,7 +18,11 @@ package lru
 
 import (
 	"fmt"
+	"strings"
 	"testing"
+	"time"
+
+	"github.com/golang/groupcache/lru/lrutest"
 )
 
 type simpleStruct struct {
@@ -100,3 +104,1066 @@ func TestEvict(t *testing.T) {
 		t.Fatalf("got %v in second evicted key; want %s", evictedKeys[1], "myKey1")
 	}
 }
+
+func TestTTL(t *testing.T) {
+	clock := lrutest.NewFakeClock(time.Unix(0, 0))
+	evictedKeys := make([]Key, 0)
+	lru := New(0)
+	lru.SetClock(clock)
+	lru.OnEvicted = func(key Key, value interface{}) {
+		evictedKeys = append(evictedKeys, key)
+	}
+
+	lru.AddWithTTL("myKey", 1234, time.Minute)
+	lru.Add("forever", 5678)
+
+	clock.Advance(30 * time.Second)
+	if d, ok := lru.TTLRemaining("myKey"); !ok || d != 30*time.Second {
+		t.Fatalf("TTLRemaining = %v, %v; want %v, true", d, ok, 30*time.Second)
+	}
+	if val, ok := lru.Get("myKey"); !ok || val != 1234 {
+		t.Fatalf("Get before expiry = %v, %v; want 1234, true", val, ok)
+	}
+
+	// 过期之后视为未命中，并且触发回调
+	clock.Advance(30 * time.Second)
+	if _, ok := lru.TTLRemaining("myKey"); ok {
+		t.Fatal("TTLRemaining returned an expired entry")
+	}
+	if _, ok := lru.Get("myKey"); ok {
+		t.Fatal("Get returned an expired entry")
+	}
+	if len(evictedKeys) != 1 || evictedKeys[0] != Key("myKey") {
+		t.Fatalf("got evicted keys %v; want [myKey]", evictedKeys)
+	}
+
+	// 通过Add添加的键值永不过期
+	clock.Advance(24 * time.Hour)
+	if d, ok := lru.TTLRemaining("forever"); !ok || d != 0 {
+		t.Fatalf("TTLRemaining = %v, %v; want 0, true", d, ok)
+	}
+	if _, ok := lru.Get("forever"); !ok {
+		t.Fatal("Get returned no match for a non-expiring entry")
+	}
+}
+
+func TestMaxBytes(t *testing.T) {
+	evictedKeys := make([]Key, 0)
+	lru := New(0)
+	lru.MaxBytes = 10
+	lru.Sizer = func(key Key, value interface{}) int64 {
+		return int64(len(value.(string)))
+	}
+	lru.OnEvicted = func(key Key, value interface{}) {
+		evictedKeys = append(evictedKeys, key)
+	}
+
+	lru.Add("myKey1", "aaaa")
+	lru.Add("myKey2", "bbbb")
+	if got := lru.Bytes(); got != 8 {
+		t.Fatalf("got %d bytes; want 8", got)
+	}
+
+	// 更新键值按照新旧字节数的差值调整
+	lru.Add("myKey1", "aa")
+	if got := lru.Bytes(); got != 6 {
+		t.Fatalf("got %d bytes; want 6", got)
+	}
+
+	// 超出字节数限制，从最老的键值开始移除
+	lru.Add("myKey3", "cccccc")
+	if len(evictedKeys) != 1 || evictedKeys[0] != Key("myKey2") {
+		t.Fatalf("got evicted keys %v; want [myKey2]", evictedKeys)
+	}
+	if got := lru.Bytes(); got != 8 {
+		t.Fatalf("got %d bytes; want 8", got)
+	}
+
+	lru.RemoveOldest()
+	if got := lru.Bytes(); got != 6 {
+		t.Fatalf("got %d bytes after RemoveOldest; want 6", got)
+	}
+}
+
+func TestMaxEntriesAndMaxBytes(t *testing.T) {
+	lru := New(2)
+	lru.MaxBytes = 100
+	lru.Sizer = func(key Key, value interface{}) int64 {
+		return value.(int64)
+	}
+
+	// 元素个数超出限制
+	lru.Add("myKey1", int64(1))
+	lru.Add("myKey2", int64(1))
+	lru.Add("myKey3", int64(1))
+	if got := lru.Len(); got != 2 {
+		t.Fatalf("got %d entries; want 2", got)
+	}
+
+	// 字节数超出限制
+	lru.Add("myKey4", int64(100))
+	if got := lru.Len(); got != 1 {
+		t.Fatalf("got %d entries; want 1", got)
+	}
+	if got := lru.Bytes(); got != 100 {
+		t.Fatalf("got %d bytes; want 100", got)
+	}
+}
+
+func TestPeek(t *testing.T) {
+	lru := New(2)
+	lru.Add("myKey1", 1234)
+	lru.Add("myKey2", 5678)
+
+	if val, ok := lru.Peek("myKey1"); !ok || val != 1234 {
+		t.Fatalf("Peek = %v, %v; want 1234, true", val, ok)
+	}
+	if _, ok := lru.Peek("nonsense"); ok {
+		t.Fatal("Peek returned a missing entry")
+	}
+
+	// Peek不会移动最老的键值，myKey1仍然会被移除
+	lru.RemoveOldest()
+	if _, ok := lru.Get("myKey1"); ok {
+		t.Fatal("Peek promoted the oldest entry")
+	}
+	if _, ok := lru.Get("myKey2"); !ok {
+		t.Fatal("RemoveOldest removed the newest entry")
+	}
+}
+
+func TestContains(t *testing.T) {
+	var nilCache *Cache
+	if nilCache.Contains("myKey") {
+		t.Fatal("nil cache contains myKey")
+	}
+	if (&Cache{}).Contains("myKey") {
+		t.Fatal("uninitialized cache contains myKey")
+	}
+
+	lru := New(2)
+	lru.Add("myKey1", 1234)
+	lru.Add("myKey2", 5678)
+	if !lru.Contains("myKey1") {
+		t.Fatal("Contains returned false for myKey1")
+	}
+	if lru.Contains("nonsense") {
+		t.Fatal("Contains returned true for a missing entry")
+	}
+
+	// Contains不会移动最老的键值
+	lru.Add("myKey3", 9012)
+	if lru.Contains("myKey1") {
+		t.Fatal("Contains promoted the oldest entry")
+	}
+}
+
+func TestKeys(t *testing.T) {
+	lru := New(0)
+	if keys := lru.Keys(); len(keys) != 0 {
+		t.Fatalf("got keys %v; want none", keys)
+	}
+
+	lru.Add("myKey1", 1234)
+	lru.Add("myKey2", 1234)
+	lru.Add("myKey3", 1234)
+	lru.Get("myKey1")
+
+	keys := lru.Keys()
+	if got, want := fmt.Sprint(keys), "[myKey1 myKey3 myKey2]"; got != want {
+		t.Fatalf("got keys %s; want %s", got, want)
+	}
+	if got, want := fmt.Sprint(lru.KeysReversed()), "[myKey2 myKey3 myKey1]"; got != want {
+		t.Fatalf("got reversed keys %s; want %s", got, want)
+	}
+
+	// 返回的切片是快照，并且Keys不会改变键值的位置
+	lru.Add("myKey4", 1234)
+	if got, want := fmt.Sprint(keys), "[myKey1 myKey3 myKey2]"; got != want {
+		t.Fatalf("snapshot changed to %s; want %s", got, want)
+	}
+	if got, want := fmt.Sprint(lru.Keys()), "[myKey4 myKey1 myKey3 myKey2]"; got != want {
+		t.Fatalf("got keys %s; want %s", got, want)
+	}
+}
+
+func TestRemoveOldest(t *testing.T) {
+	lru := New(0)
+	if _, _, ok := lru.GetOldest(); ok {
+		t.Fatal("GetOldest returned an entry from an empty cache")
+	}
+	if _, _, ok := lru.RemoveOldest(); ok {
+		t.Fatal("RemoveOldest removed an entry from an empty cache")
+	}
+
+	lru.Add("myKey1", 1234)
+	lru.Add("myKey2", 5678)
+
+	// GetOldest不会移除键值
+	for i := 0; i < 2; i++ {
+		key, val, ok := lru.GetOldest()
+		if !ok || key != Key("myKey1") || val != 1234 {
+			t.Fatalf("GetOldest = %v, %v, %v; want myKey1, 1234, true", key, val, ok)
+		}
+	}
+
+	key, val, ok := lru.RemoveOldest()
+	if !ok || key != Key("myKey1") || val != 1234 {
+		t.Fatalf("RemoveOldest = %v, %v, %v; want myKey1, 1234, true", key, val, ok)
+	}
+	if got := lru.Len(); got != 1 {
+		t.Fatalf("got %d entries; want 1", got)
+	}
+}
+
+func TestRange(t *testing.T) {
+	lru := New(0)
+	lru.Add("myKey1", 1)
+	lru.Add("myKey2", 2)
+	lru.Add("myKey3", 3)
+
+	var keys []Key
+	lru.Range(func(key Key, value interface{}) bool {
+		keys = append(keys, key)
+		return true
+	})
+	if got, want := fmt.Sprint(keys), "[myKey3 myKey2 myKey1]"; got != want {
+		t.Fatalf("got keys %s; want %s", got, want)
+	}
+
+	// f返回false时停止遍历
+	keys = keys[:0]
+	lru.Range(func(key Key, value interface{}) bool {
+		keys = append(keys, key)
+		return value.(int) != 2
+	})
+	if got, want := fmt.Sprint(keys), "[myKey3 myKey2]"; got != want {
+		t.Fatalf("got keys %s; want %s", got, want)
+	}
+
+	// Range不会改变键值的位置
+	if got, want := fmt.Sprint(lru.Keys()), "[myKey3 myKey2 myKey1]"; got != want {
+		t.Fatalf("got keys %s after Range; want %s", got, want)
+	}
+}
+
+func TestStats(t *testing.T) {
+	lru := New(2)
+	lru.Get("myKey1")
+	lru.Add("myKey1", 1234)
+	lru.Add("myKey2", 1234)
+	lru.Get("myKey1")
+	lru.Get("myKey2")
+	lru.Add("myKey3", 1234)
+	lru.Get("myKey1")
+	lru.Remove("myKey2")
+
+	// 主动移除的键值不计入Evictions
+	want := Stats{Hits: 2, Misses: 2, Evictions: 1}
+	if got := lru.Stats(); got != want {
+		t.Fatalf("got stats %+v; want %+v", got, want)
+	}
+
+	lru.ResetStats()
+	if got := lru.Stats(); got != (Stats{}) {
+		t.Fatalf("got stats %+v after ResetStats; want zero", got)
+	}
+}
+
+func TestMaxCost(t *testing.T) {
+	evictedKeys := make([]Key, 0)
+	lru := New(0)
+	lru.MaxCost = 10
+	lru.OnEvicted = func(key Key, value interface{}) {
+		evictedKeys = append(evictedKeys, key)
+	}
+
+	lru.AddWithCost("myKey1", 1234, 4)
+	lru.AddWithCost("myKey2", 1234, 4)
+	if got := lru.Cost(); got != 8 {
+		t.Fatalf("got cost %d; want 8", got)
+	}
+
+	// 重新添加键值按照新旧权重的差值调整
+	lru.AddWithCost("myKey1", 1234, 2)
+	if got := lru.Cost(); got != 6 {
+		t.Fatalf("got cost %d; want 6", got)
+	}
+
+	// 权重总和超出限制，从最老的键值开始移除
+	lru.AddWithCost("myKey3", 1234, 6)
+	if len(evictedKeys) != 1 || evictedKeys[0] != Key("myKey2") {
+		t.Fatalf("got evicted keys %v; want [myKey2]", evictedKeys)
+	}
+	if got := lru.Cost(); got != 8 {
+		t.Fatalf("got cost %d; want 8", got)
+	}
+
+	lru.Clear()
+	if got := lru.Cost(); got != 0 {
+		t.Fatalf("got cost %d after Clear; want 0", got)
+	}
+}
+
+func TestResize(t *testing.T) {
+	evictedKeys := make([]Key, 0)
+	lru := New(4)
+	lru.OnEvicted = func(key Key, value interface{}) {
+		evictedKeys = append(evictedKeys, key)
+	}
+	for i := 0; i < 4; i++ {
+		lru.Add(fmt.Sprintf("myKey%d", i), i)
+	}
+
+	// 缩小容量，移除最老的键值
+	if n := lru.Resize(2); n != 2 {
+		t.Fatalf("Resize evicted %d entries; want 2", n)
+	}
+	if got, want := fmt.Sprint(evictedKeys), "[myKey0 myKey1]"; got != want {
+		t.Fatalf("got evicted keys %s; want %s", got, want)
+	}
+
+	// 改为没有限制，不会移除键值
+	if n := lru.Resize(0); n != 0 {
+		t.Fatalf("Resize evicted %d entries; want 0", n)
+	}
+	for i := 4; i < 10; i++ {
+		lru.Add(fmt.Sprintf("myKey%d", i), i)
+	}
+	if got := lru.Len(); got != 8 {
+		t.Fatalf("got %d entries; want 8", got)
+	}
+
+	// 扩大容量，不会移除键值
+	if n := lru.Resize(100); n != 0 {
+		t.Fatalf("Resize evicted %d entries; want 0", n)
+	}
+}
+
+func TestGetOrAdd(t *testing.T) {
+	calls := 0
+	compute := func() interface{} {
+		calls++
+		return 1234
+	}
+
+	lru := New(0)
+	val, loaded := lru.GetOrAdd("myKey", compute)
+	if loaded || val != 1234 {
+		t.Fatalf("GetOrAdd = %v, %v; want 1234, false", val, loaded)
+	}
+	val, loaded = lru.GetOrAdd("myKey", compute)
+	if !loaded || val != 1234 {
+		t.Fatalf("GetOrAdd = %v, %v; want 1234, true", val, loaded)
+	}
+
+	// compute只在未命中时调用
+	if calls != 1 {
+		t.Fatalf("compute called %d times; want 1", calls)
+	}
+}
+
+func TestUpdate(t *testing.T) {
+	lru := New(0)
+	if lru.Update("myKey1", 1234) {
+		t.Fatal("Update returned true for a missing entry")
+	}
+	if lru.Len() != 0 {
+		t.Fatal("Update inserted a missing entry")
+	}
+
+	lru.Add("myKey1", 1)
+	lru.Add("myKey2", 2)
+	if !lru.Update("myKey1", 1234) {
+		t.Fatal("Update returned false for myKey1")
+	}
+	if val, _ := lru.Peek("myKey1"); val != 1234 {
+		t.Fatalf("got %v after Update; want 1234", val)
+	}
+
+	// Update不会改变键值的位置
+	if got, want := fmt.Sprint(lru.Keys()), "[myKey2 myKey1]"; got != want {
+		t.Fatalf("got keys %s; want %s", got, want)
+	}
+}
+
+func TestTouch(t *testing.T) {
+	if (&Cache{}).Touch("myKey") {
+		t.Fatal("Touch returned true on an uninitialized cache")
+	}
+
+	lru := New(2)
+	lru.Add("myKey1", 1)
+	lru.Add("myKey2", 2)
+	if lru.Touch("nonsense") {
+		t.Fatal("Touch returned true for a missing entry")
+	}
+	if !lru.Touch("myKey1") {
+		t.Fatal("Touch returned false for myKey1")
+	}
+
+	// myKey1被移动到最前面，myKey2被移除
+	lru.Add("myKey3", 3)
+	if !lru.Contains("myKey1") || lru.Contains("myKey2") {
+		t.Fatalf("got keys %v; want myKey1 to survive", lru.Keys())
+	}
+}
+
+func TestEvictReason(t *testing.T) {
+	clock := lrutest.NewFakeClock(time.Unix(0, 0))
+	reasons := make(map[Key]EvictReason)
+	lru := New(2)
+	lru.SetClock(clock)
+	lru.OnEvictedWithReason = func(key Key, value interface{}, reason EvictReason) {
+		reasons[key] = reason
+	}
+
+	lru.Add("capacity", 1)
+	lru.Add("manual", 2)
+	lru.Add("expired", 3)
+	lru.Remove("manual")
+	lru.AddWithTTL("expired", 3, time.Second)
+	clock.Advance(time.Second)
+	lru.Get("expired")
+	lru.Add("clear", 4)
+	lru.Clear()
+
+	want := map[Key]EvictReason{
+		"capacity": ReasonCapacity,
+		"manual":   ReasonManual,
+		"expired":  ReasonExpired,
+		"clear":    ReasonClear,
+	}
+	if got := fmt.Sprint(reasons); got != fmt.Sprint(want) {
+		t.Fatalf("got reasons %s; want %s", got, fmt.Sprint(want))
+	}
+}
+
+func TestPurgeExpired(t *testing.T) {
+	clock := lrutest.NewFakeClock(time.Unix(0, 0))
+	reasons := make([]EvictReason, 0)
+	lru := New(0)
+	lru.SetClock(clock)
+	lru.OnEvictedWithReason = func(key Key, value interface{}, reason EvictReason) {
+		reasons = append(reasons, reason)
+	}
+
+	lru.AddWithTTL("myKey1", 1, time.Second)
+	lru.AddWithTTL("myKey2", 2, time.Minute)
+	lru.AddWithTTL("myKey3", 3, time.Second)
+	lru.Add("myKey4", 4)
+
+	clock.Advance(time.Second)
+	if n := lru.PurgeExpired(); n != 2 {
+		t.Fatalf("PurgeExpired removed %d entries; want 2", n)
+	}
+	if got, want := fmt.Sprint(lru.Keys()), "[myKey4 myKey2]"; got != want {
+		t.Fatalf("got keys %s; want %s", got, want)
+	}
+	if got, want := fmt.Sprint(reasons), "[expired expired]"; got != want {
+		t.Fatalf("got reasons %s; want %s", got, want)
+	}
+}
+
+func TestClone(t *testing.T) {
+	lru := New(3)
+	lru.Add("myKey1", 1)
+	lru.Add("myKey2", 2)
+	lru.Add("myKey3", 3)
+	lru.Get("myKey1")
+
+	clone := lru.Clone()
+	if clone.MaxEntries != 3 {
+		t.Fatalf("got MaxEntries %d; want 3", clone.MaxEntries)
+	}
+	if got, want := fmt.Sprint(clone.Keys()), fmt.Sprint(lru.Keys()); got != want {
+		t.Fatalf("got clone keys %s; want %s", got, want)
+	}
+
+	// 修改其中一个缓存不会影响另一个
+	clone.Add("myKey4", 4)
+	lru.Remove("myKey1")
+	if got, want := fmt.Sprint(lru.Keys()), "[myKey3 myKey2]"; got != want {
+		t.Fatalf("got original keys %s; want %s", got, want)
+	}
+	if got, want := fmt.Sprint(clone.Keys()), "[myKey4 myKey1 myKey3]"; got != want {
+		t.Fatalf("got clone keys %s; want %s", got, want)
+	}
+}
+
+func TestAddReportsEviction(t *testing.T) {
+	lru := New(2)
+	if _, evicted := lru.Add("myKey1", 1); evicted {
+		t.Fatal("Add evicted an entry from a non-full cache")
+	}
+	lru.Add("myKey2", 2)
+
+	// 更新已缓存的键值不会移除键值
+	if _, evicted := lru.Add("myKey1", 11); evicted {
+		t.Fatal("Add evicted an entry when updating an existing key")
+	}
+
+	key, evicted := lru.Add("myKey3", 3)
+	if !evicted || key != Key("myKey2") {
+		t.Fatalf("Add = %v, %v; want myKey2, true", key, evicted)
+	}
+}
+
+func TestPin(t *testing.T) {
+	lru := New(2)
+	lru.Add("pinned", 1)
+	lru.Pin("pinned")
+	lru.Add("myKey1", 2)
+
+	// 跳过固定的键值，移除最老的没有固定的键值
+	key, evicted := lru.Add("myKey2", 3)
+	if !evicted || key != Key("myKey1") {
+		t.Fatalf("Add = %v, %v; want myKey1, true", key, evicted)
+	}
+
+	// 其他键值都被固定时允许超出最大限制
+	lru.Pin("myKey2")
+	if _, evicted := lru.Add("myKey3", 4); evicted {
+		t.Fatal("Add evicted a pinned entry")
+	}
+	lru.Pin("myKey3")
+	if _, evicted := lru.Add("myKey4", 5); evicted {
+		t.Fatal("Add evicted a pinned entry")
+	}
+	if got := lru.Len(); got != 4 {
+		t.Fatalf("got %d entries; want 4", got)
+	}
+	lru.Pin("myKey4")
+	if _, _, ok := lru.RemoveOldest(); ok {
+		t.Fatal("RemoveOldest removed a pinned entry")
+	}
+
+	// 固定的键值可以主动移除
+	lru.Remove("pinned")
+	if lru.Contains("pinned") {
+		t.Fatal("Remove did not remove a pinned entry")
+	}
+
+	// 取消固定之后恢复最大限制
+	lru.Unpin("myKey2")
+	lru.Unpin("myKey3")
+	lru.Add("myKey5", 6)
+	if got, want := fmt.Sprint(lru.Keys()), "[myKey5 myKey4]"; got != want {
+		t.Fatalf("got keys %s; want %s", got, want)
+	}
+}
+
+func TestPop(t *testing.T) {
+	reasons := make([]EvictReason, 0)
+	lru := New(0)
+	lru.OnEvictedWithReason = func(key Key, value interface{}, reason EvictReason) {
+		reasons = append(reasons, reason)
+	}
+	if _, _, ok := lru.Pop(); ok {
+		t.Fatal("Pop returned an entry from an empty cache")
+	}
+
+	lru.Add("myKey1", 1)
+	lru.Add("myKey2", 2)
+	lru.Add("myKey3", 3)
+
+	key, val, ok := lru.Pop()
+	if !ok || key != Key("myKey1") || val != 1 {
+		t.Fatalf("Pop = %v, %v, %v; want myKey1, 1, true", key, val, ok)
+	}
+	if got, want := fmt.Sprint(reasons), "[manual]"; got != want {
+		t.Fatalf("got reasons %s; want %s", got, want)
+	}
+
+	// PopSilent不触发回调函数
+	key, val, ok = lru.PopSilent()
+	if !ok || key != Key("myKey2") || val != 2 {
+		t.Fatalf("PopSilent = %v, %v, %v; want myKey2, 2, true", key, val, ok)
+	}
+	if len(reasons) != 1 {
+		t.Fatalf("got %d callbacks; want 1", len(reasons))
+	}
+	if got := lru.Len(); got != 1 {
+		t.Fatalf("got %d entries; want 1", got)
+	}
+}
+
+func TestMaxValueBytes(t *testing.T) {
+	rejected := make([]Key, 0)
+	lru := New(2)
+	lru.MaxValueBytes = 4
+	lru.Sizer = func(key Key, value interface{}) int64 {
+		return int64(len(value.(string)))
+	}
+	lru.OnRejected = func(key Key, size int64) {
+		rejected = append(rejected, key)
+	}
+	lru.Add("myKey1", "aaaa")
+	lru.Add("myKey2", "bbbb")
+
+	// 超出限制的键值不会被添加，也不会移除已缓存的键值
+	if _, evicted := lru.Add("huge", "ccccc"); evicted {
+		t.Fatal("oversize Add evicted an entry")
+	}
+	if lru.TryAdd("huge", "ccccc") {
+		t.Fatal("TryAdd stored an oversize value")
+	}
+	if lru.Contains("huge") {
+		t.Fatal("oversize value was stored")
+	}
+	if lru.Update("myKey1", "ccccc") {
+		t.Fatal("Update stored an oversize value")
+	}
+	if val, _ := lru.Peek("myKey1"); val != "aaaa" {
+		t.Fatalf("got %v after oversize Update; want aaaa", val)
+	}
+	if got, want := fmt.Sprint(lru.Keys()), "[myKey2 myKey1]"; got != want {
+		t.Fatalf("got keys %s; want %s", got, want)
+	}
+	if got, want := fmt.Sprint(rejected), "[huge huge myKey1]"; got != want {
+		t.Fatalf("got rejected keys %s; want %s", got, want)
+	}
+	if got := lru.Stats().Rejections; got != 3 {
+		t.Fatalf("got %d rejections; want 3", got)
+	}
+
+	if !lru.TryAdd("myKey3", "cccc") {
+		t.Fatal("TryAdd rejected a value within the limit")
+	}
+}
+
+func TestGetStale(t *testing.T) {
+	clock := lrutest.NewFakeClock(time.Unix(0, 0))
+	lru := New(0)
+	lru.SetClock(clock)
+	lru.AddWithTTL("myKey", 1234, time.Second)
+
+	// 未命中
+	if _, _, ok := lru.GetStale("nonsense"); ok {
+		t.Fatal("GetStale returned a missing entry")
+	}
+
+	// 命中，未过期
+	val, expired, ok := lru.GetStale("myKey")
+	if !ok || expired || val != 1234 {
+		t.Fatalf("GetStale = %v, %v, %v; want 1234, false, true", val, expired, ok)
+	}
+
+	// 命中，已过期，不会被移除
+	clock.Advance(time.Second)
+	val, expired, ok = lru.GetStale("myKey")
+	if !ok || !expired || val != 1234 {
+		t.Fatalf("GetStale = %v, %v, %v; want 1234, true, true", val, expired, ok)
+	}
+	if got := lru.Len(); got != 1 {
+		t.Fatalf("got %d entries; want 1", got)
+	}
+
+	// Get仍然移除已过期的键值
+	if _, ok := lru.Get("myKey"); ok {
+		t.Fatal("Get returned an expired entry")
+	}
+	if got := lru.Len(); got != 0 {
+		t.Fatalf("got %d entries; want 0", got)
+	}
+}
+
+func TestString(t *testing.T) {
+	lru := New(10)
+	if got, want := lru.String(), "lru.Cache{len=0 max=10}"; got != want {
+		t.Fatalf("got %q; want %q", got, want)
+	}
+
+	lru.Add("myKey1", 1)
+	lru.Add("myKey2", 2)
+	lru.Get("myKey1")
+	if got, want := lru.String(), "lru.Cache{len=2 max=10: myKey1=1 myKey2=2}"; got != want {
+		t.Fatalf("got %q; want %q", got, want)
+	}
+
+	// String不会改变键值的位置，超过20个键值时省略
+	lru = New(0)
+	for i := 0; i < 25; i++ {
+		lru.Add(i, i)
+	}
+	if got, want := lru.String(), "...(5 more)}"; !strings.HasSuffix(got, want) {
+		t.Fatalf("got %q; want suffix %q", got, want)
+	}
+	if key, _, _ := lru.GetOldest(); key != 0 {
+		t.Fatalf("got oldest key %v after String; want 0", key)
+	}
+}
+
+func TestGetMulti(t *testing.T) {
+	lru := New(3)
+	lru.Add("myKey1", 1)
+	lru.Add("myKey2", 2)
+	lru.Add("myKey3", 3)
+
+	values, found := lru.GetMulti([]Key{"myKey2", "nonsense", "myKey1"})
+	if got, want := fmt.Sprint(values, found), "[2 <nil> 1] [true false true]"; got != want {
+		t.Fatalf("GetMulti = %s; want %s", got, want)
+	}
+
+	// 命中的键值依次被移动到最前面
+	if got, want := fmt.Sprint(lru.Keys()), "[myKey1 myKey2 myKey3]"; got != want {
+		t.Fatalf("got keys %s; want %s", got, want)
+	}
+}
+
+func TestAddMulti(t *testing.T) {
+	lru := New(3)
+	evictedKeys := make([]Key, 0)
+	lru.OnEvicted = func(key Key, value interface{}) {
+		evictedKeys = append(evictedKeys, key)
+	}
+	lru.Add("myKey0", 0)
+
+	n := lru.AddMulti([]Entry{
+		{"myKey1", 1},
+		{"myKey2", 2},
+		{"myKey1", 10},
+		{"myKey3", 3},
+	})
+	if n != 1 {
+		t.Fatalf("got %d evicted; want 1", n)
+	}
+	if got, want := fmt.Sprint(evictedKeys), "[myKey0]"; got != want {
+		t.Fatalf("got evicted keys %s; want %s", got, want)
+	}
+	// 重复的键只更新，不会重复添加
+	if got, want := lru.String(), "lru.Cache{len=3 max=3: myKey3=3 myKey1=10 myKey2=2}"; got != want {
+		t.Fatalf("got %s; want %s", got, want)
+	}
+}
+
+// 零值和清空之后的缓存也可以添加空的一批键值
+func TestAddMultiEmpty(t *testing.T) {
+	var zero Cache
+	if n := zero.AddMulti(nil); n != 0 {
+		t.Fatalf("AddMulti(nil) on zero Cache evicted %d; want 0", n)
+	}
+
+	lru := New(2)
+	lru.Add("myKey1", 1)
+	lru.Clear()
+	if n := lru.AddMulti(nil); n != 0 {
+		t.Fatalf("AddMulti(nil) after Clear evicted %d; want 0", n)
+	}
+	if got := lru.Len(); got != 0 {
+		t.Fatalf("got %d entries; want 0", got)
+	}
+}
+
+// 缓存已满时添加一批新的键值
+func benchmarkBatch(b *testing.B, add func(lru *Cache, entries []Entry)) {
+	lru := New(1000)
+	entries := make([]Entry, 100)
+	for i := 0; i < b.N; i++ {
+		for j := range entries {
+			entries[j] = Entry{i*len(entries) + j, j}
+		}
+		add(lru, entries)
+	}
+}
+
+func BenchmarkAdd(b *testing.B) {
+	benchmarkBatch(b, func(lru *Cache, entries []Entry) {
+		for _, e := range entries {
+			lru.Add(e.Key, e.Value)
+		}
+	})
+}
+
+func BenchmarkAddMulti(b *testing.B) {
+	benchmarkBatch(b, func(lru *Cache, entries []Entry) {
+		lru.AddMulti(entries)
+	})
+}
+
+func TestRemoveFunc(t *testing.T) {
+	lru := New(0)
+	reasons := make([]EvictReason, 0)
+	lru.OnEvictedWithReason = func(key Key, value interface{}, reason EvictReason) {
+		reasons = append(reasons, reason)
+	}
+	lru.Add("tenant1/a", 1)
+	lru.Add("tenant2/a", 2)
+	lru.Add("tenant1/b", 3)
+	lru.Add("tenant2/b", 4)
+
+	n := lru.RemoveFunc(func(key Key, value interface{}) bool {
+		return strings.HasPrefix(key.(string), "tenant1/")
+	})
+	if n != 2 {
+		t.Fatalf("got %d removed; want 2", n)
+	}
+	if got, want := fmt.Sprint(reasons), "[manual manual]"; got != want {
+		t.Fatalf("got reasons %s; want %s", got, want)
+	}
+	// 剩下的键值保持原来的顺序
+	if got, want := fmt.Sprint(lru.Keys()), "[tenant2/b tenant2/a]"; got != want {
+		t.Fatalf("got keys %s; want %s", got, want)
+	}
+}
+
+func TestUtilization(t *testing.T) {
+	lru := New(4)
+	lru.Add("myKey1", 1)
+	if got := lru.Cap(); got != 4 {
+		t.Fatalf("got Cap %d; want 4", got)
+	}
+	if got := lru.Utilization(); got != 0.25 {
+		t.Fatalf("got Utilization %v; want 0.25", got)
+	}
+
+	// 没有限制时不能除以0
+	lru = New(0)
+	lru.Add("myKey1", 1)
+	if got := lru.Utilization(); got != 0 {
+		t.Fatalf("got Utilization %v; want 0", got)
+	}
+}
+
+func TestTimestamps(t *testing.T) {
+	lru := New(0)
+	created := time.Date(2013, 1, 1, 0, 0, 0, 0, time.UTC)
+	clock := lrutest.NewFakeClock(created)
+	lru.SetClock(clock)
+
+	lru.Add("myKey", 1)
+	clock.Advance(time.Second)
+	lru.Get("myKey")
+	if got, ok := lru.LastAccessed("myKey"); !ok || !got.Equal(clock.Now()) {
+		t.Fatalf("LastAccessed = %v, %v; want %v, true", got, ok, clock.Now())
+	}
+
+	// 更新键值刷新访问时间，但是不改变添加时间
+	clock.Advance(time.Second)
+	lru.Add("myKey", 2)
+	if got, ok := lru.LastAccessed("myKey"); !ok || !got.Equal(clock.Now()) {
+		t.Fatalf("LastAccessed = %v, %v; want %v, true", got, ok, clock.Now())
+	}
+	if got, ok := lru.CreatedAt("myKey"); !ok || !got.Equal(created) {
+		t.Fatalf("CreatedAt = %v, %v; want %v, true", got, ok, created)
+	}
+
+	if _, ok := lru.CreatedAt("nonsense"); ok {
+		t.Fatal("CreatedAt returned ok for a missing key")
+	}
+}
+
+func TestMaxAge(t *testing.T) {
+	clock := lrutest.NewFakeClock(time.Unix(0, 0))
+	reasons := make(map[Key]EvictReason)
+	lru := New(0)
+	lru.MaxAge = time.Minute
+	lru.SetClock(clock)
+	lru.OnEvictedWithReason = func(key Key, value interface{}, reason EvictReason) {
+		reasons[key] = reason
+	}
+
+	lru.Add("old", 1)
+	lru.AddWithTTL("ttl", 2, time.Second)
+	clock.Advance(30 * time.Second)
+	lru.Add("new", 3)
+
+	// 访问不会延长MaxAge
+	lru.Get("old")
+	if got, ok := lru.TTLRemaining("old"); !ok || got != 30*time.Second {
+		t.Fatalf("TTLRemaining = %v, %v; want 30s, true", got, ok)
+	}
+	clock.Advance(30 * time.Second)
+	if _, ok := lru.Get("old"); ok {
+		t.Fatal("Get returned an entry older than MaxAge")
+	}
+	if n := lru.PurgeExpired(); n != 1 {
+		t.Fatalf("PurgeExpired removed %d entries; want 1", n)
+	}
+	if n := lru.PurgeOlderThan(30 * time.Second); n != 1 {
+		t.Fatalf("PurgeOlderThan removed %d entries; want 1", n)
+	}
+
+	want := map[Key]EvictReason{
+		"old": ReasonMaxAge,
+		"ttl": ReasonExpired,
+		"new": ReasonMaxAge,
+	}
+	if got := fmt.Sprint(reasons); got != fmt.Sprint(want) {
+		t.Fatalf("got reasons %s; want %s", got, fmt.Sprint(want))
+	}
+}
+
+func TestReplace(t *testing.T) {
+	lru := New(0)
+	if old, existed := lru.Replace("myKey1", 1); existed || old != nil {
+		t.Fatalf("Replace = %v, %v; want <nil>, false", old, existed)
+	}
+	lru.Add("myKey2", 2)
+
+	if old, existed := lru.Replace("myKey1", 10); !existed || old != 1 {
+		t.Fatalf("Replace = %v, %v; want 1, true", old, existed)
+	}
+	// 和Add一样移动到最前面
+	if got, want := lru.String(), "lru.Cache{len=2 max=0: myKey1=10 myKey2=2}"; got != want {
+		t.Fatalf("got %s; want %s", got, want)
+	}
+}
+
+func TestAddIfAbsent(t *testing.T) {
+	lru := New(0)
+	if actual, inserted := lru.AddIfAbsent("myKey1", 1); !inserted || actual != 1 {
+		t.Fatalf("AddIfAbsent = %v, %v; want 1, true", actual, inserted)
+	}
+	lru.Add("myKey2", 2)
+
+	// 已存在的键值不会被覆盖，但是会移动到最前面
+	if actual, inserted := lru.AddIfAbsent("myKey1", 10); inserted || actual != 1 {
+		t.Fatalf("AddIfAbsent = %v, %v; want 1, false", actual, inserted)
+	}
+	if got, want := lru.String(), "lru.Cache{len=2 max=0: myKey1=1 myKey2=2}"; got != want {
+		t.Fatalf("got %s; want %s", got, want)
+	}
+}
+
+func TestCompareAndSwap(t *testing.T) {
+	lru := New(0)
+	lru.Add("myKey", 1)
+	_, version, ok := lru.GetWithVersion("myKey")
+	if !ok {
+		t.Fatal("GetWithVersion returned no entry")
+	}
+
+	// 版本号不一致时拒绝更新
+	if lru.CompareAndSwap("myKey", version+1, 2) {
+		t.Fatal("CompareAndSwap accepted a stale version")
+	}
+	if !lru.CompareAndSwap("myKey", version, 2) {
+		t.Fatal("CompareAndSwap rejected the current version")
+	}
+	val, newVersion, _ := lru.GetWithVersion("myKey")
+	if val != 2 || newVersion <= version {
+		t.Fatalf("GetWithVersion = %v, %d; want 2 and a version greater than %d", val, newVersion, version)
+	}
+	if lru.CompareAndSwap("myKey", version, 3) {
+		t.Fatal("CompareAndSwap accepted the version it replaced")
+	}
+
+	// 移除之后再添加，版本号不会重复
+	lru.Remove("myKey")
+	lru.Add("myKey", 4)
+	if _, v, _ := lru.GetWithVersion("myKey"); v == newVersion {
+		t.Fatalf("got reused version %d after re-adding", v)
+	}
+}
+
+// 对比预分配哈希表和不预分配时添加MaxEntries个键值的内存分配
+func BenchmarkWarmup(b *testing.B) {
+	const n = 10000
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		lru := New(n)
+		for j := 0; j < n; j++ {
+			lru.Add(j, j)
+		}
+	}
+}
+
+func BenchmarkWarmupNoPrealloc(b *testing.B) {
+	const n = 10000
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		lru := &Cache{MaxEntries: n}
+		for j := 0; j < n; j++ {
+			lru.Add(j, j)
+		}
+	}
+}
+
+func TestShrink(t *testing.T) {
+	lru := New(0)
+	for i := 0; i < 10000; i++ {
+		lru.Add(i, i)
+	}
+	lru.RemoveFunc(func(key Key, value interface{}) bool {
+		return key.(int) >= 3
+	})
+	ele := lru.cache[1]
+
+	lru.Shrink()
+	if got, want := fmt.Sprint(lru.Keys()), "[2 1 0]"; got != want {
+		t.Fatalf("got keys %s after Shrink; want %s", got, want)
+	}
+	if len(lru.cache) != 3 || lru.cache[1] != ele {
+		t.Fatal("Shrink did not preserve the list elements")
+	}
+	if val, ok := lru.Get(1); !ok || val != 1 {
+		t.Fatalf("Get = %v, %v; want 1, true", val, ok)
+	}
+}
+
+func TestFreeze(t *testing.T) {
+	lru := New(2)
+	evictedKeys := make([]Key, 0)
+	lru.OnEvicted = func(key Key, value interface{}) {
+		evictedKeys = append(evictedKeys, key)
+	}
+	lru.Add("myKey1", 1)
+	lru.Add("myKey2", 2)
+
+	lru.Freeze()
+	lru.Add("myKey3", 3)
+	lru.Add("myKey4", 4)
+	lru.Add("myKey1", 10)
+	if len(evictedKeys) != 0 {
+		t.Fatalf("got evicted keys %v while frozen; want none", evictedKeys)
+	}
+	if got := lru.Len(); got != 4 {
+		t.Fatalf("got %d entries while frozen; want 4", got)
+	}
+
+	if n := lru.Unfreeze(); n != 2 {
+		t.Fatalf("Unfreeze removed %d entries; want 2", n)
+	}
+	if got, want := fmt.Sprint(lru.Keys()), "[myKey1 myKey4]"; got != want {
+		t.Fatalf("got keys %s; want %s", got, want)
+	}
+}
+
+// 零值和清空之后的缓存也可以解除冻结
+func TestUnfreezeEmpty(t *testing.T) {
+	var zero Cache
+	if n := zero.Unfreeze(); n != 0 {
+		t.Fatalf("Unfreeze on zero Cache removed %d entries; want 0", n)
+	}
+
+	lru := New(2)
+	lru.Add("myKey1", 1)
+	lru.Clear()
+	if n := lru.Unfreeze(); n != 0 {
+		t.Fatalf("Unfreeze after Clear removed %d entries; want 0", n)
+	}
+}
+
+func TestEvictMode(t *testing.T) {
+	tests := []struct {
+		mode EvictMode
+		want string
+	}{
+		{ModeLRU, "[myKey4 myKey2 myKey3]"},
+		{ModeFIFO, "[myKey4 myKey3 myKey2]"},
+		{ModeMRU, "[myKey4 myKey3 myKey1]"},
+	}
+	for _, tt := range tests {
+		lru := New(3)
+		lru.Mode = tt.mode
+		lru.Add("myKey1", 1)
+		lru.Add("myKey2", 2)
+		lru.Add("myKey3", 3)
+		lru.Get("myKey2")
+		lru.Add("myKey4", 4)
+		if got := fmt.Sprint(lru.Keys()); got != tt.want {
+			t.Errorf("mode %d: got keys %s; want %s", tt.mode, got, tt.want)
+		}
+	}
+}
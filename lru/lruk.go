@@ -0,0 +1,147 @@
+/*
+Copyright 2013 Google Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package lru
+
+// LRUK是LRU-K算法的实现，不是并发安全的
+// 每个键值记录最近K次访问的时间，缓存已满时移除第K次最近访问最早的键值
+// 访问次数不足K次的键值视为第K次访问无限早，优先被移除，其中最近一次访问最早的先被移除
+// 只被访问一次的扫描数据不会移除访问过K次的热数据
+// 移除时需要遍历所有键值，时间复杂度是O(n)
+type LRUK struct {
+	// 缓存元素的最大数量限制，0 代表没有限制
+	MaxEntries int
+
+	// 缓存元素被移除的时候触发的回调函数
+	OnEvicted func(key Key, value interface{})
+
+	k     int
+	tick  uint64 // 逻辑时间，每次访问加1
+	cache map[interface{}]*lruKEntry
+}
+
+// LRU-K的键值对，history按照从早到晚的顺序保存最近K次访问的逻辑时间
+type lruKEntry struct {
+	key     Key
+	value   interface{}
+	history []uint64
+}
+
+// LRUK结构的构造函数，k必须大于0，k为1时等同于LRU
+func NewLRUK(maxEntries, k int) *LRUK {
+	if k <= 0 {
+		panic("lru: LRUK k must be positive")
+	}
+	return &LRUK{
+		MaxEntries: maxEntries,
+		k:          k,
+		cache:      make(map[interface{}]*lruKEntry),
+	}
+}
+
+// 添加键值到缓存，更新已缓存的键值视为一次访问
+func (c *LRUK) Add(key Key, value interface{}) {
+	if kv, ok := c.cache[key]; ok {
+		kv.value = value
+		c.access(kv)
+		return
+	}
+	if c.MaxEntries != 0 && len(c.cache) >= c.MaxEntries {
+		c.evict()
+	}
+	kv := &lruKEntry{key: key, value: value, history: make([]uint64, 0, c.k)}
+	c.access(kv)
+	c.cache[key] = kv
+}
+
+// 记录一次访问，只保留最近K次
+func (c *LRUK) access(kv *lruKEntry) {
+	c.tick++
+	if len(kv.history) == c.k {
+		copy(kv.history, kv.history[1:])
+		kv.history = kv.history[:c.k-1]
+	}
+	kv.history = append(kv.history, c.tick)
+}
+
+// 移除第K次最近访问最早的键值
+func (c *LRUK) evict() {
+	var victim *lruKEntry
+	for _, kv := range c.cache {
+		if victim == nil || c.older(kv, victim) {
+			victim = kv
+		}
+	}
+	if victim != nil {
+		c.removeEntry(victim)
+	}
+}
+
+// 判断a是否比b更应该被移除
+func (c *LRUK) older(a, b *lruKEntry) bool {
+	ak, bk := c.kth(a), c.kth(b)
+	if ak != bk {
+		return ak < bk
+	}
+	return a.history[len(a.history)-1] < b.history[len(b.history)-1]
+}
+
+// 第K次最近访问的逻辑时间，访问次数不足K次时返回0
+func (c *LRUK) kth(kv *lruKEntry) uint64 {
+	if len(kv.history) < c.k {
+		return 0
+	}
+	return kv.history[0]
+}
+
+// 从缓存中获取键值，命中时记录一次访问
+func (c *LRUK) Get(key Key) (value interface{}, ok bool) {
+	kv, hit := c.cache[key]
+	if !hit {
+		return
+	}
+	c.access(kv)
+	return kv.value, true
+}
+
+// 从缓存中移除键值
+func (c *LRUK) Remove(key Key) {
+	if kv, hit := c.cache[key]; hit {
+		c.removeEntry(kv)
+	}
+}
+
+func (c *LRUK) removeEntry(kv *lruKEntry) {
+	delete(c.cache, kv.key)
+	if c.OnEvicted != nil {
+		c.OnEvicted(kv.key, kv.value)
+	}
+}
+
+// 获取缓存的元素数量
+func (c *LRUK) Len() int {
+	return len(c.cache)
+}
+
+// 重置缓存，清除所有元素和访问记录
+func (c *LRUK) Clear() {
+	if c.OnEvicted != nil {
+		for _, kv := range c.cache {
+			c.OnEvicted(kv.key, kv.value)
+		}
+	}
+	c.cache = make(map[interface{}]*lruKEntry)
+}
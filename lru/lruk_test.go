@@ -0,0 +1,55 @@
+/*
+Copyright 2013 Google Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package lru
+
+import (
+	"fmt"
+	"testing"
+)
+
+// 访问过K次的键值不会被只访问一次的扫描数据移除
+func TestLRUKHotSurvivesScan(t *testing.T) {
+	c := NewLRUK(4, 2)
+	c.Add("hot", 1)
+	c.Get("hot")
+
+	for i := 0; i < 10; i++ {
+		c.Add(fmt.Sprintf("cold%d", i), i)
+	}
+	if _, ok := c.Get("hot"); !ok {
+		t.Fatal("hot was evicted by a scan")
+	}
+	if got := c.Len(); got != 4 {
+		t.Fatalf("got %d entries; want 4", got)
+	}
+}
+
+// 访问次数都不足K次时，最近一次访问最早的先被移除
+func TestLRUKRecencyTiebreak(t *testing.T) {
+	c := NewLRUK(2, 2)
+	evictedKeys := make([]Key, 0)
+	c.OnEvicted = func(key Key, value interface{}) {
+		evictedKeys = append(evictedKeys, key)
+	}
+	c.Add("myKey1", 1)
+	c.Add("myKey2", 2)
+	c.Add("myKey3", 3)
+	c.Add("myKey4", 4)
+	if got, want := fmt.Sprint(evictedKeys), "[myKey1 myKey2]"; got != want {
+		t.Fatalf("got evicted keys %s; want %s", got, want)
+	}
+}
@@ -0,0 +1,205 @@
+/*
+Copyright 2013 Google Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package lru
+
+import (
+	"container/list"
+	"math/rand"
+	"time"
+)
+
+// EvictionPolicy决定缓存超出最大限制时移除哪个键值
+// Cache在添加、访问和移除键值时通知EvictionPolicy，需要移除键值时调用Victim
+type EvictionPolicy interface {
+	// 记录新添加的键值
+	Add(key Key)
+	// 记录键值被访问，包括Get命中、Touch和更新已缓存的键值
+	Access(key Key)
+	// 记录键值被移除
+	Remove(key Key)
+	// 返回下一个应该被移除的键值，没有键值时返回false
+	Victim() (Key, bool)
+	// 清除所有记录
+	Clear()
+	// 复制当前的记录，返回的策略和原来的策略互不影响，Cache.Clone时调用
+	Clone() EvictionPolicy
+}
+
+// 按照双向链表顺序选择键值的移除策略
+type listPolicy struct {
+	ll      *list.List
+	keys    map[interface{}]*list.Element
+	promote bool // 访问键值时是否移动到最前面
+}
+
+// 移除最近没有使用的键值，和不设置Policy时的行为一致
+func NewLRUPolicy() EvictionPolicy {
+	return &listPolicy{
+		ll:      list.New(),
+		keys:    make(map[interface{}]*list.Element),
+		promote: true,
+	}
+}
+
+// 移除最早添加的键值，访问键值不改变移除顺序
+func NewFIFOPolicy() EvictionPolicy {
+	return &listPolicy{
+		ll:   list.New(),
+		keys: make(map[interface{}]*list.Element),
+	}
+}
+
+func (p *listPolicy) Add(key Key) {
+	p.keys[key] = p.ll.PushFront(key)
+}
+
+func (p *listPolicy) Access(key Key) {
+	if ele, ok := p.keys[key]; ok && p.promote {
+		p.ll.MoveToFront(ele)
+	}
+}
+
+func (p *listPolicy) Remove(key Key) {
+	if ele, ok := p.keys[key]; ok {
+		p.ll.Remove(ele)
+		delete(p.keys, key)
+	}
+}
+
+func (p *listPolicy) Victim() (Key, bool) {
+	ele := p.ll.Back()
+	if ele == nil {
+		return nil, false
+	}
+	return ele.Value, true
+}
+
+func (p *listPolicy) Clear() {
+	p.ll.Init()
+	p.keys = make(map[interface{}]*list.Element)
+}
+
+func (p *listPolicy) Clone() EvictionPolicy {
+	clone := &listPolicy{
+		ll:      list.New(),
+		keys:    make(map[interface{}]*list.Element, len(p.keys)),
+		promote: p.promote,
+	}
+	for e := p.ll.Front(); e != nil; e = e.Next() {
+		clone.keys[e.Value] = clone.ll.PushBack(e.Value)
+	}
+	return clone
+}
+
+// 近似LRU默认的采样数量
+const defaultSamples = 5
+
+// 近似LRU的移除策略，和Redis一样随机采样k个键值，移除其中最久没有访问的键值
+type sampledPolicy struct {
+	k     int
+	tick  uint64 // 逻辑时钟，每次添加或者访问键值递增
+	keys  []sampledKey
+	index map[interface{}]int // 键值在keys中的下标
+	rand  *rand.Rand
+}
+
+// 采样的键值和最后访问的逻辑时间
+type sampledKey struct {
+	key        Key
+	lastAccess uint64
+}
+
+// 随机采样k个键值，移除其中最久没有访问的键值，k小于等于0时使用默认值5
+// 不需要像LRU一样在每次访问时调整链表，k越大越接近LRU
+func NewSampledPolicy(k int) EvictionPolicy {
+	if k <= 0 {
+		k = defaultSamples
+	}
+	return &sampledPolicy{
+		k:     k,
+		index: make(map[interface{}]int),
+		rand:  rand.New(rand.NewSource(time.Now().UnixNano())),
+	}
+}
+
+func (p *sampledPolicy) Add(key Key) {
+	p.tick++
+	p.index[key] = len(p.keys)
+	p.keys = append(p.keys, sampledKey{key, p.tick})
+}
+
+func (p *sampledPolicy) Access(key Key) {
+	if i, ok := p.index[key]; ok {
+		p.tick++
+		p.keys[i].lastAccess = p.tick
+	}
+}
+
+// 将最后一个键值移到被删除的位置
+func (p *sampledPolicy) Remove(key Key) {
+	i, ok := p.index[key]
+	if !ok {
+		return
+	}
+	last := len(p.keys) - 1
+	p.keys[i] = p.keys[last]
+	p.index[p.keys[i].key] = i
+	p.keys = p.keys[:last]
+	delete(p.index, key)
+}
+
+func (p *sampledPolicy) Victim() (Key, bool) {
+	if len(p.keys) == 0 {
+		return nil, false
+	}
+	victim := p.sample()
+	for i := 1; i < p.k; i++ {
+		if sk := p.sample(); sk.lastAccess < victim.lastAccess {
+			victim = sk
+		}
+	}
+	return victim.key, true
+}
+
+// 随机选择一个键值，跳过最近添加或者访问的键值，它通常是添加之后需要保留的键值
+func (p *sampledPolicy) sample() sampledKey {
+	i := p.rand.Intn(len(p.keys))
+	if p.keys[i].lastAccess == p.tick && len(p.keys) > 1 {
+		i = (i + 1) % len(p.keys)
+	}
+	return p.keys[i]
+}
+
+func (p *sampledPolicy) Clear() {
+	p.keys = nil
+	p.index = make(map[interface{}]int)
+}
+
+// 复制的策略使用新的随机数生成器，rand.Rand不能被并发使用
+func (p *sampledPolicy) Clone() EvictionPolicy {
+	clone := &sampledPolicy{
+		k:     p.k,
+		tick:  p.tick,
+		keys:  append([]sampledKey(nil), p.keys...),
+		index: make(map[interface{}]int, len(p.index)),
+		rand:  rand.New(rand.NewSource(p.rand.Int63())),
+	}
+	for key, i := range p.index {
+		clone.index[key] = i
+	}
+	return clone
+}
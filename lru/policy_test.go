@@ -0,0 +1,187 @@
+/*
+Copyright 2013 Google Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package lru
+
+import (
+	"fmt"
+	"math/rand"
+	"testing"
+)
+
+// 按照相同的访问顺序，返回被移除的键值
+func evictionOrder(policy EvictionPolicy) string {
+	evictedKeys := make([]Key, 0)
+	lru := New(3)
+	lru.Policy = policy
+	lru.OnEvicted = func(key Key, value interface{}) {
+		evictedKeys = append(evictedKeys, key)
+	}
+	lru.Add("myKey1", 1)
+	lru.Add("myKey2", 2)
+	lru.Add("myKey3", 3)
+	lru.Get("myKey1")
+	lru.Add("myKey2", 22)
+	lru.Add("myKey4", 4)
+	lru.Add("myKey5", 5)
+	return fmt.Sprint(evictedKeys)
+}
+
+func TestLRUPolicy(t *testing.T) {
+	if got, want := evictionOrder(NewLRUPolicy()), evictionOrder(nil); got != want {
+		t.Fatalf("got evicted keys %s; want %s", got, want)
+	}
+}
+
+func TestFIFOPolicy(t *testing.T) {
+	// FIFO不会因为访问而改变移除顺序
+	if got, want := evictionOrder(NewFIFOPolicy()), "[myKey1 myKey2]"; got != want {
+		t.Fatalf("got evicted keys %s; want %s", got, want)
+	}
+}
+
+func TestPolicyRemoveAndClear(t *testing.T) {
+	lru := New(2)
+	lru.Policy = NewFIFOPolicy()
+	lru.Add("myKey1", 1)
+	lru.Add("myKey2", 2)
+	lru.Remove("myKey1")
+	lru.Add("myKey3", 3)
+	if got, want := fmt.Sprint(lru.Keys()), "[myKey3 myKey2]"; got != want {
+		t.Fatalf("got keys %s; want %s", got, want)
+	}
+
+	// Clear之后Policy中没有残留的键值
+	lru.Clear()
+	if _, ok := lru.Policy.Victim(); ok {
+		t.Fatal("Policy returned a victim after Clear")
+	}
+	lru.Add("myKey4", 4)
+	lru.Add("myKey5", 5)
+	lru.Add("myKey6", 6)
+	if got, want := fmt.Sprint(lru.Keys()), "[myKey6 myKey5]"; got != want {
+		t.Fatalf("got keys %s; want %s", got, want)
+	}
+}
+
+// 复制的缓存使用独立的Policy，修改其中一个缓存不会影响另一个的移除顺序
+func TestClonePolicy(t *testing.T) {
+	for _, policy := range []EvictionPolicy{NewFIFOPolicy(), NewSampledPolicy(0)} {
+		lru := New(2)
+		lru.Policy = policy
+		lru.Add("myKey1", 1)
+		lru.Add("myKey2", 2)
+
+		clone := lru.Clone()
+		if clone.Policy == lru.Policy {
+			t.Fatalf("%T: clone shares the Policy", policy)
+		}
+		lru.Remove("myKey1")
+		lru.Add("myKey3", 3)
+		clone.Add("myKey4", 4)
+		if got, want := fmt.Sprint(lru.Keys()), "[myKey3 myKey2]"; got != want {
+			t.Errorf("%T: got original keys %s; want %s", policy, got, want)
+		}
+		if clone.Len() != 2 || !clone.Contains("myKey4") {
+			t.Errorf("%T: got clone keys %v; want myKey4 and one other", policy, clone.Keys())
+		}
+		if _, ok := clone.Policy.Victim(); !ok {
+			t.Errorf("%T: clone Policy has no victim", policy)
+		}
+	}
+
+	// FIFO的顺序也被复制
+	lru := New(2)
+	lru.Policy = NewFIFOPolicy()
+	lru.Add("myKey1", 1)
+	lru.Add("myKey2", 2)
+	clone := lru.Clone()
+	lru.Remove("myKey1")
+	clone.Add("myKey3", 3)
+	if got, want := fmt.Sprint(clone.Keys()), "[myKey3 myKey2]"; got != want {
+		t.Fatalf("got clone keys %s; want %s", got, want)
+	}
+}
+
+// 移除的键值按照访问顺序排名的平均值，排名越小越接近LRU
+func meanVictimRank(k int) float64 {
+	const n = 1000
+	policy := NewSampledPolicy(k).(*sampledPolicy)
+	policy.rand = rand.New(rand.NewSource(1))
+
+	lru := New(n)
+	lru.Policy = policy
+	for i := 0; i < n; i++ {
+		lru.Add(i, i)
+	}
+
+	// 键值i按照访问顺序的排名就是i，移除之后重新添加的键值是最新的，保持键值数量不变
+	total := 0
+	for i := 0; i < 100; i++ {
+		key, _, _ := lru.RemoveOldest()
+		total += key.(int)
+		lru.Add(key, key)
+	}
+	return float64(total) / 100
+}
+
+func TestSampledPolicy(t *testing.T) {
+	// 随机移除的平均排名接近n/2，采样5个时接近n/6
+	random := meanVictimRank(1)
+	sampled := meanVictimRank(5)
+	if random < 350 {
+		t.Fatalf("got mean rank %.1f for k=1; want about 500", random)
+	}
+	if sampled > 250 {
+		t.Fatalf("got mean rank %.1f for k=5; want about 167", sampled)
+	}
+}
+
+// 采样选中刚添加的键值时也要移除其他键值，不能超出最大限制
+func TestSampledPolicyMaxEntries(t *testing.T) {
+	lru := New(2)
+	lru.Policy = NewSampledPolicy(1)
+	for i := 0; i < 1000; i++ {
+		lru.Add(i, i)
+		if got := lru.Len(); got > lru.MaxEntries {
+			t.Fatalf("after %d adds got %d entries; want at most %d", i+1, got, lru.MaxEntries)
+		}
+		if !lru.Contains(i) {
+			t.Fatalf("newly added key %d was evicted", i)
+		}
+	}
+}
+
+func TestSampledPolicyRemove(t *testing.T) {
+	lru := New(2)
+	lru.Policy = NewSampledPolicy(0)
+	lru.Add("myKey1", 1)
+	lru.Add("myKey2", 2)
+	lru.Remove("myKey1")
+	lru.Add("myKey3", 3)
+	lru.Add("myKey4", 4)
+	if got := lru.Len(); got != 2 {
+		t.Fatalf("got %d entries; want 2", got)
+	}
+	if !lru.Contains("myKey4") {
+		t.Fatal("newly added key was evicted")
+	}
+
+	lru.Clear()
+	if _, ok := lru.Policy.Victim(); ok {
+		t.Fatal("Policy returned a victim after Clear")
+	}
+}
@@ -0,0 +1,500 @@
+/*
+Copyright 2013 Google Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package lru
+
+import (
+	"io"
+	"sync"
+	"time"
+)
+
+// SafeCache是并发安全的Cache，所有方法都在持有锁的情况下转发给内部的Cache
+// Get会调用MoveToFront修改双向链表，所以不能使用读写锁的读锁
+type SafeCache struct {
+	mu    sync.Mutex
+	cache *Cache
+
+	// 用户设置的回调函数，在释放锁之后才调用，回调函数可以再次访问缓存
+	onEvicted           func(key Key, value interface{})
+	onEvictedWithReason func(key Key, value interface{}, reason EvictReason)
+	// 持有锁期间被移除的键值，等待释放锁之后触发回调
+	evicted []evictedEntry
+
+	// 合并GetOrLoadDedup对同一个键的并发加载
+	loads map[Key]*loadCall
+	// 正在后台提前刷新的键
+	refreshing map[interface{}]struct{}
+
+	// 后台清理过期键值的协程，stop关闭时协程退出，退出之后关闭done
+	janitorStop chan struct{}
+	janitorDone chan struct{}
+}
+
+// 被移除的键值和移除的原因
+type evictedEntry struct {
+	key    Key
+	value  interface{}
+	reason EvictReason
+}
+
+// 包装一个Cache，返回并发安全的SafeCache
+// 包装之后不能再直接访问c，c.OnEvicted和c.OnEvictedWithReason会在释放锁之后调用
+func ThreadSafe(c *Cache) *SafeCache {
+	s := &SafeCache{
+		cache:               c,
+		onEvicted:           c.OnEvicted,
+		onEvictedWithReason: c.OnEvictedWithReason,
+	}
+	if s.onEvicted != nil || s.onEvictedWithReason != nil {
+		c.OnEvicted = nil
+		c.OnEvictedWithReason = func(key Key, value interface{}, reason EvictReason) {
+			s.evicted = append(s.evicted, evictedEntry{key, value, reason})
+		}
+	}
+	return s
+}
+
+// 释放锁，并触发持有锁期间收集的移除回调
+func (s *SafeCache) unlock() {
+	evicted := s.evicted
+	s.evicted = nil
+	s.mu.Unlock()
+	for _, kv := range evicted {
+		if s.onEvictedWithReason != nil {
+			s.onEvictedWithReason(kv.key, kv.value, kv.reason)
+		} else {
+			s.onEvicted(kv.key, kv.value)
+		}
+	}
+}
+
+// 添加键值到缓存
+func (s *SafeCache) Add(key Key, value interface{}) (evictedKey Key, evicted bool) {
+	s.mu.Lock()
+	defer s.unlock()
+	return s.cache.Add(key, value)
+}
+
+// 批量添加键值，只获取一次锁
+func (s *SafeCache) AddMulti(entries []Entry) (evicted int) {
+	s.mu.Lock()
+	defer s.unlock()
+	return s.cache.AddMulti(entries)
+}
+
+// 添加或者替换键值，返回替换之前的value
+func (s *SafeCache) Replace(key Key, value interface{}) (old interface{}, existed bool) {
+	s.mu.Lock()
+	defer s.unlock()
+	return s.cache.Replace(key, value)
+}
+
+// 添加键值到缓存，返回键值是否被添加
+func (s *SafeCache) TryAdd(key Key, value interface{}) bool {
+	s.mu.Lock()
+	defer s.unlock()
+	return s.cache.TryAdd(key, value)
+}
+
+// 添加键值到缓存，键值在ttl之后过期
+func (s *SafeCache) AddWithTTL(key Key, value interface{}, ttl time.Duration) (evictedKey Key, evicted bool) {
+	s.mu.Lock()
+	defer s.unlock()
+	return s.cache.AddWithTTL(key, value, ttl)
+}
+
+// 添加键值到缓存，并指定键值的权重
+func (s *SafeCache) AddWithCost(key Key, value interface{}, cost int64) (evictedKey Key, evicted bool) {
+	s.mu.Lock()
+	defer s.unlock()
+	return s.cache.AddWithCost(key, value, cost)
+}
+
+// 将已缓存的键值移动到双向链表的最前面
+func (s *SafeCache) Touch(key Key) bool {
+	s.mu.Lock()
+	defer s.unlock()
+	return s.cache.Touch(key)
+}
+
+// 更新已缓存键值的value，不改变键值的位置
+func (s *SafeCache) Update(key Key, value interface{}) bool {
+	s.mu.Lock()
+	defer s.unlock()
+	return s.cache.Update(key, value)
+}
+
+// 从缓存中获取键值
+// 设置了RefreshAheadFactor和Loader时，快要过期的键值会在后台重新加载，立即返回当前的value
+func (s *SafeCache) Get(key Key) (value interface{}, ok bool) {
+	s.mu.Lock()
+	value, ok = s.cache.Get(key)
+	ttl, version, refresh := time.Duration(0), uint64(0), false
+	if ok {
+		ttl, version, refresh = s.startRefresh(key)
+	}
+	s.unlock()
+
+	if refresh {
+		go s.refresh(key, ttl, version)
+	}
+	return value, ok
+}
+
+// 从缓存中获取键值，如果未命中，调用compute计算value并添加到缓存
+// compute在持有锁的情况下调用，不能再访问缓存
+func (s *SafeCache) GetOrAdd(key Key, compute func() interface{}) (value interface{}, loaded bool) {
+	s.mu.Lock()
+	defer s.unlock()
+	return s.cache.GetOrAdd(key, compute)
+}
+
+// 键值不存在时添加键值，已存在时返回已缓存的value
+func (s *SafeCache) AddIfAbsent(key Key, value interface{}) (actual interface{}, inserted bool) {
+	s.mu.Lock()
+	defer s.unlock()
+	return s.cache.AddIfAbsent(key, value)
+}
+
+// 批量获取键值，只获取一次锁
+func (s *SafeCache) GetMulti(keys []Key) (values []interface{}, found []bool) {
+	s.mu.Lock()
+	defer s.unlock()
+	return s.cache.GetMulti(keys)
+}
+
+// 从缓存中获取键值，不改变键值的位置
+func (s *SafeCache) Peek(key Key) (value interface{}, ok bool) {
+	s.mu.Lock()
+	defer s.unlock()
+	return s.cache.Peek(key)
+}
+
+// 判断键值是否已缓存，不改变键值的位置
+func (s *SafeCache) Contains(key Key) bool {
+	s.mu.Lock()
+	defer s.unlock()
+	return s.cache.Contains(key)
+}
+
+// 从缓存中获取键值和版本号
+func (s *SafeCache) GetWithVersion(key Key) (value interface{}, version uint64, ok bool) {
+	s.mu.Lock()
+	defer s.unlock()
+	return s.cache.GetWithVersion(key)
+}
+
+// 只有键值的版本号等于expectedVersion时才更新value
+func (s *SafeCache) CompareAndSwap(key Key, expectedVersion uint64, newValue interface{}) bool {
+	s.mu.Lock()
+	defer s.unlock()
+	return s.cache.CompareAndSwap(key, expectedVersion, newValue)
+}
+
+// 从缓存中获取键值，已过期的键值也会返回，expired为true
+func (s *SafeCache) GetStale(key Key) (value interface{}, expired bool, ok bool) {
+	s.mu.Lock()
+	defer s.unlock()
+	return s.cache.GetStale(key)
+}
+
+// 获取键值剩余的存活时间
+func (s *SafeCache) TTLRemaining(key Key) (time.Duration, bool) {
+	s.mu.Lock()
+	defer s.unlock()
+	return s.cache.TTLRemaining(key)
+}
+
+// 获取键值最近一次被访问的时间
+func (s *SafeCache) LastAccessed(key Key) (time.Time, bool) {
+	s.mu.Lock()
+	defer s.unlock()
+	return s.cache.LastAccessed(key)
+}
+
+// 获取键值被添加的时间
+func (s *SafeCache) CreatedAt(key Key) (time.Time, bool) {
+	s.mu.Lock()
+	defer s.unlock()
+	return s.cache.CreatedAt(key)
+}
+
+// 从缓存中移除键值
+func (s *SafeCache) Remove(key Key) {
+	s.mu.Lock()
+	defer s.unlock()
+	s.cache.Remove(key)
+}
+
+// 从缓存中移除最老的键值，返回被移除的键值
+func (s *SafeCache) RemoveOldest() (key Key, value interface{}, ok bool) {
+	s.mu.Lock()
+	defer s.unlock()
+	return s.cache.RemoveOldest()
+}
+
+// 移除并返回最老的没有固定的键值，触发回调函数
+func (s *SafeCache) Pop() (key Key, value interface{}, ok bool) {
+	s.mu.Lock()
+	defer s.unlock()
+	return s.cache.Pop()
+}
+
+// 移除并返回最老的没有固定的键值，不触发回调函数
+func (s *SafeCache) PopSilent() (key Key, value interface{}, ok bool) {
+	s.mu.Lock()
+	defer s.unlock()
+	return s.cache.PopSilent()
+}
+
+// 固定已缓存的键值，固定的键值不会因为超出最大限制被移除
+func (s *SafeCache) Pin(key Key) {
+	s.mu.Lock()
+	defer s.unlock()
+	s.cache.Pin(key)
+}
+
+// 取消固定键值
+func (s *SafeCache) Unpin(key Key) {
+	s.mu.Lock()
+	defer s.unlock()
+	s.cache.Unpin(key)
+}
+
+// 获取缓存中最老的键值，不会移除键值
+func (s *SafeCache) GetOldest() (key Key, value interface{}, ok bool) {
+	s.mu.Lock()
+	defer s.unlock()
+	return s.cache.GetOldest()
+}
+
+// 移除所有已过期的键值，返回移除的数量
+func (s *SafeCache) PurgeExpired() int {
+	s.mu.Lock()
+	defer s.unlock()
+	return s.cache.PurgeExpired()
+}
+
+// 移除所有pred返回true的键值，返回移除的数量
+// pred在持有锁的情况下调用，不能再访问缓存
+func (s *SafeCache) RemoveFunc(pred func(key Key, value interface{}) bool) int {
+	s.mu.Lock()
+	defer s.unlock()
+	return s.cache.RemoveFunc(pred)
+}
+
+// 移除所有添加时间距今达到d的键值，返回移除的数量
+func (s *SafeCache) PurgeOlderThan(d time.Duration) int {
+	s.mu.Lock()
+	defer s.unlock()
+	return s.cache.PurgeOlderThan(d)
+}
+
+// 启动后台协程，每隔interval移除所有已过期的键值
+// 如果协程已经启动，先停止原来的协程
+func (s *SafeCache) StartJanitor(interval time.Duration) {
+	s.StopJanitor()
+
+	stop := make(chan struct{})
+	done := make(chan struct{})
+	s.mu.Lock()
+	s.janitorStop = stop
+	s.janitorDone = done
+	s.mu.Unlock()
+
+	go func() {
+		defer close(done)
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-stop:
+				return
+			case <-ticker.C:
+				s.PurgeExpired()
+			}
+		}
+	}()
+}
+
+// 停止后台清理协程并等待协程退出，可以重复调用
+func (s *SafeCache) StopJanitor() {
+	s.mu.Lock()
+	stop, done := s.janitorStop, s.janitorDone
+	s.janitorStop, s.janitorDone = nil, nil
+	s.mu.Unlock()
+
+	if stop != nil {
+		close(stop)
+		<-done
+	}
+}
+
+// 冻结缓存，冻结期间不会因为超出最大限制移除键值
+func (s *SafeCache) Freeze() {
+	s.mu.Lock()
+	defer s.unlock()
+	s.cache.Freeze()
+}
+
+// 解除冻结，返回移除的数量
+func (s *SafeCache) Unfreeze() int {
+	s.mu.Lock()
+	defer s.unlock()
+	return s.cache.Unfreeze()
+}
+
+// 修改缓存元素的最大数量限制，返回移除的数量
+func (s *SafeCache) Resize(maxEntries int) int {
+	s.mu.Lock()
+	defer s.unlock()
+	return s.cache.Resize(maxEntries)
+}
+
+// 设置获取当前时间的Clock
+func (s *SafeCache) SetClock(clock Clock) {
+	s.mu.Lock()
+	defer s.unlock()
+	s.cache.SetClock(clock)
+}
+
+// 获取缓存的元素数量
+func (s *SafeCache) Len() int {
+	s.mu.Lock()
+	defer s.unlock()
+	return s.cache.Len()
+}
+
+// 按照当前的元素数量重建哈希表，释放多占用的内存
+func (s *SafeCache) Shrink() {
+	s.mu.Lock()
+	defer s.unlock()
+	s.cache.Shrink()
+}
+
+// 获取缓存元素的最大数量
+func (s *SafeCache) Cap() int {
+	s.mu.Lock()
+	defer s.unlock()
+	return s.cache.Cap()
+}
+
+// 获取元素数量占最大数量的比例
+func (s *SafeCache) Utilization() float64 {
+	s.mu.Lock()
+	defer s.unlock()
+	return s.cache.Utilization()
+}
+
+// 按照最近使用到最久未使用的顺序返回所有键值
+func (s *SafeCache) Keys() []Key {
+	s.mu.Lock()
+	defer s.unlock()
+	return s.cache.Keys()
+}
+
+// 按照最久未使用到最近使用的顺序返回所有键值
+func (s *SafeCache) KeysReversed() []Key {
+	s.mu.Lock()
+	defer s.unlock()
+	return s.cache.KeysReversed()
+}
+
+// 按照最近使用到最久未使用的顺序遍历所有键值，f返回false时停止遍历
+// 遍历期间持有锁，在f中访问缓存会导致死锁
+func (s *SafeCache) Range(f func(key Key, value interface{}) bool) {
+	s.mu.Lock()
+	defer s.unlock()
+	s.cache.Range(f)
+}
+
+// 按照最近使用到最久未使用的顺序输出键值，用于调试
+func (s *SafeCache) String() string {
+	s.mu.Lock()
+	defer s.unlock()
+	return s.cache.String()
+}
+
+// 返回访问次数最多的topN个键
+func (s *SafeCache) HotKeys(topN int) []HotKey {
+	s.mu.Lock()
+	defer s.unlock()
+	return s.cache.HotKeys(topN)
+}
+
+// 获取缓存元素占用的字节数
+func (s *SafeCache) Bytes() int64 {
+	s.mu.Lock()
+	defer s.unlock()
+	return s.cache.Bytes()
+}
+
+// 获取缓存元素的权重总和
+func (s *SafeCache) Cost() int64 {
+	s.mu.Lock()
+	defer s.unlock()
+	return s.cache.Cost()
+}
+
+// 获取缓存统计数据的副本
+func (s *SafeCache) Stats() Stats {
+	s.mu.Lock()
+	defer s.unlock()
+	return s.cache.Stats()
+}
+
+// 将缓存统计数据清零
+func (s *SafeCache) ResetStats() {
+	s.mu.Lock()
+	defer s.unlock()
+	s.cache.ResetStats()
+}
+
+// 获取移除通道
+func (s *SafeCache) EvictChan() <-chan Evicted {
+	s.mu.Lock()
+	defer s.unlock()
+	return s.cache.EvictChan()
+}
+
+// 关闭移除通道，可以重复调用
+func (s *SafeCache) CloseEvictChan() {
+	s.mu.Lock()
+	defer s.unlock()
+	s.cache.CloseEvictChan()
+}
+
+// 将缓存的键值写入w
+func (s *SafeCache) SnapshotTo(w io.Writer) error {
+	s.mu.Lock()
+	defer s.unlock()
+	return s.cache.SnapshotTo(w)
+}
+
+// 从r读取快照，替换缓存中已有的键值
+func (s *SafeCache) LoadFrom(r io.Reader) error {
+	s.mu.Lock()
+	defer s.unlock()
+	return s.cache.LoadFrom(r)
+}
+
+// 重置缓存，清除所有元素
+func (s *SafeCache) Clear() {
+	s.mu.Lock()
+	defer s.unlock()
+	s.cache.Clear()
+}
@@ -0,0 +1,145 @@
+/*
+Copyright 2013 Google Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package lru
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+)
+
+// 多个协程并发执行Get和Add，需要配合-race运行
+func TestSafeCacheConcurrent(t *testing.T) {
+	c := ThreadSafe(New(100))
+
+	const n = 16
+	var wg sync.WaitGroup
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			for j := 0; j < 1000; j++ {
+				key := fmt.Sprintf("myKey%d", (i+j)%200)
+				c.Add(key, j)
+				c.Get(key)
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	if got := c.Len(); got != 100 {
+		t.Fatalf("got %d entries; want 100", got)
+	}
+}
+
+// 回调函数再次访问缓存不能出现死锁
+func TestSafeCacheEvictCallback(t *testing.T) {
+	lru := New(1)
+	var s *SafeCache
+	evictedKeys := make([]Key, 0)
+	lru.OnEvicted = func(key Key, value interface{}) {
+		evictedKeys = append(evictedKeys, key)
+		s.Len()
+	}
+	s = ThreadSafe(lru)
+
+	s.Add("myKey1", 1234)
+	s.Add("myKey2", 1234)
+	s.Remove("myKey2")
+
+	if len(evictedKeys) != 2 {
+		t.Fatalf("got %d evicted keys; want 2", len(evictedKeys))
+	}
+	if evictedKeys[0] != Key("myKey1") {
+		t.Fatalf("got %v in first evicted key; want %s", evictedKeys[0], "myKey1")
+	}
+	if evictedKeys[1] != Key("myKey2") {
+		t.Fatalf("got %v in second evicted key; want %s", evictedKeys[1], "myKey2")
+	}
+}
+
+// 并发调用GetOrAdd，compute只会执行一次
+func TestSafeCacheGetOrAdd(t *testing.T) {
+	c := ThreadSafe(New(0))
+	var calls int
+	var wg sync.WaitGroup
+	for i := 0; i < 16; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			c.GetOrAdd("myKey", func() interface{} {
+				calls++
+				return 1234
+			})
+		}()
+	}
+	wg.Wait()
+
+	if calls != 1 {
+		t.Fatalf("compute called %d times; want 1", calls)
+	}
+}
+
+// 带有原因的回调函数同样在释放锁之后调用
+func TestSafeCacheEvictReason(t *testing.T) {
+	lru := New(1)
+	var s *SafeCache
+	reasons := make([]EvictReason, 0)
+	lru.OnEvictedWithReason = func(key Key, value interface{}, reason EvictReason) {
+		reasons = append(reasons, reason)
+		s.Len()
+	}
+	s = ThreadSafe(lru)
+
+	s.Add("myKey1", 1234)
+	s.Add("myKey2", 1234)
+	s.Remove("myKey2")
+
+	if got, want := fmt.Sprint(reasons), "[capacity manual]"; got != want {
+		t.Fatalf("got reasons %s; want %s", got, want)
+	}
+}
+
+func TestSafeCacheJanitor(t *testing.T) {
+	lru := New(0)
+	expired := make(chan Key, 1)
+	lru.OnEvictedWithReason = func(key Key, value interface{}, reason EvictReason) {
+		if reason == ReasonExpired {
+			expired <- key
+		}
+	}
+	s := ThreadSafe(lru)
+	s.AddWithTTL("myKey", 1234, time.Millisecond)
+
+	s.StartJanitor(time.Millisecond)
+	select {
+	case key := <-expired:
+		if key != Key("myKey") {
+			t.Fatalf("got expired key %v; want myKey", key)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("janitor did not purge the expired entry")
+	}
+
+	// 可以重复停止
+	s.StopJanitor()
+	s.StopJanitor()
+	if got := s.Len(); got != 0 {
+		t.Fatalf("got %d entries; want 0", got)
+	}
+}
@@ -0,0 +1,147 @@
+/*
+Copyright 2013 Google Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package lru
+
+import "container/list"
+
+// Segmented是分段LRU的实现，不是并发安全的
+// 新键值进入试用段，在试用段中再次被访问时晋升到保护段
+// 保护段超出限制时，最久未使用的键值降级到试用段的最前面
+// 只被访问一次的键值只会占用试用段，不会移除保护段中的热数据
+type Segmented struct {
+	// 缓存元素离开试用段被移除的时候触发的回调函数，降级不会触发
+	OnEvicted func(key Key, value interface{})
+
+	size        int // 缓存元素的最大数量
+	protectedSz int // 保护段的最大数量
+
+	probation *list.List
+	protected *list.List
+	cache     map[interface{}]*list.Element
+}
+
+// 分段LRU的键值对，记录所在的段
+type segmentedEntry struct {
+	key   Key
+	value interface{}
+	list  *list.List
+}
+
+// Segmented结构的构造函数，保护段占总容量的80%
+func NewSegmented(size int) *Segmented {
+	return NewSegmentedParams(size, defaultProtectedRatio)
+}
+
+// Segmented结构的构造函数，指定保护段占总容量的比例
+func NewSegmentedParams(size int, protectedRatio float64) *Segmented {
+	if size <= 0 {
+		panic("lru: Segmented size must be positive")
+	}
+	protectedSz := int(float64(size) * protectedRatio)
+	if protectedSz >= size {
+		// 至少给试用段保留一个位置
+		protectedSz = size - 1
+	}
+	if protectedSz < 0 {
+		protectedSz = 0
+	}
+	return &Segmented{
+		size:        size,
+		protectedSz: protectedSz,
+		probation:   list.New(),
+		protected:   list.New(),
+		cache:       make(map[interface{}]*list.Element),
+	}
+}
+
+// 添加键值到缓存，已缓存的键值更新value，和Get一样视为一次访问
+func (c *Segmented) Add(key Key, value interface{}) {
+	if ele, ok := c.cache[key]; ok {
+		ele.Value.(*segmentedEntry).value = value
+		c.access(ele)
+		return
+	}
+	c.cache[key] = c.probation.PushFront(&segmentedEntry{key, value, c.probation})
+	if c.Len() > c.size {
+		c.removeElement(c.probation.Back())
+	}
+}
+
+// 从缓存中获取键值
+func (c *Segmented) Get(key Key) (value interface{}, ok bool) {
+	ele, hit := c.cache[key]
+	if !hit {
+		return
+	}
+	c.access(ele)
+	return ele.Value.(*segmentedEntry).value, true
+}
+
+// 键值被访问，试用段的键值晋升到保护段，保护段的键值移动到最前面
+func (c *Segmented) access(ele *list.Element) {
+	kv := ele.Value.(*segmentedEntry)
+	if kv.list == c.protected || c.protectedSz == 0 {
+		kv.list.MoveToFront(ele)
+		return
+	}
+	c.probation.Remove(ele)
+	kv.list = c.protected
+	c.cache[kv.key] = c.protected.PushFront(kv)
+
+	if c.protected.Len() > c.protectedSz {
+		demoted := c.protected.Back()
+		dkv := demoted.Value.(*segmentedEntry)
+		c.protected.Remove(demoted)
+		dkv.list = c.probation
+		c.cache[dkv.key] = c.probation.PushFront(dkv)
+	}
+}
+
+// 从缓存中移除键值
+func (c *Segmented) Remove(key Key) {
+	if ele, hit := c.cache[key]; hit {
+		c.removeElement(ele)
+	}
+}
+
+// 从所在的段中移除键值
+func (c *Segmented) removeElement(e *list.Element) {
+	kv := e.Value.(*segmentedEntry)
+	kv.list.Remove(e)
+	delete(c.cache, kv.key)
+	if c.OnEvicted != nil {
+		c.OnEvicted(kv.key, kv.value)
+	}
+}
+
+// 获取缓存的元素数量
+func (c *Segmented) Len() int {
+	return c.probation.Len() + c.protected.Len()
+}
+
+// 重置缓存，清除所有元素
+func (c *Segmented) Clear() {
+	if c.OnEvicted != nil {
+		for _, e := range c.cache {
+			kv := e.Value.(*segmentedEntry)
+			c.OnEvicted(kv.key, kv.value)
+		}
+	}
+	c.probation.Init()
+	c.protected.Init()
+	c.cache = make(map[interface{}]*list.Element)
+}
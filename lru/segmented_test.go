@@ -0,0 +1,70 @@
+/*
+Copyright 2013 Google Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package lru
+
+import (
+	"fmt"
+	"testing"
+)
+
+// 第二次访问晋升到保护段，不会被试用段的新键值挤掉
+func TestSegmentedPromotion(t *testing.T) {
+	c := NewSegmentedParams(4, 0.5)
+	evictedKeys := make([]Key, 0)
+	c.OnEvicted = func(key Key, value interface{}) {
+		evictedKeys = append(evictedKeys, key)
+	}
+	c.Add("hot", 1)
+	c.Get("hot")
+
+	for i := 0; i < 4; i++ {
+		c.Add(fmt.Sprintf("cold%d", i), i)
+	}
+	if _, ok := c.Get("hot"); !ok {
+		t.Fatal("promoted key was evicted")
+	}
+	if got, want := fmt.Sprint(evictedKeys), "[cold0]"; got != want {
+		t.Fatalf("got evicted keys %s; want %s", got, want)
+	}
+}
+
+// 保护段已满时，最老的键值降级到试用段，降级不会触发回调函数
+func TestSegmentedDemotion(t *testing.T) {
+	c := NewSegmentedParams(4, 0.5)
+	evictedKeys := make([]Key, 0)
+	c.OnEvicted = func(key Key, value interface{}) {
+		evictedKeys = append(evictedKeys, key)
+	}
+	for i := 1; i <= 3; i++ {
+		key := fmt.Sprintf("myKey%d", i)
+		c.Add(key, i)
+		c.Get(key)
+	}
+	if len(evictedKeys) != 0 {
+		t.Fatalf("got evicted keys %v on demotion; want none", evictedKeys)
+	}
+	if c.protected.Len() != 2 || c.probation.Front().Value.(*segmentedEntry).key != "myKey1" {
+		t.Fatal("myKey1 was not demoted to the front of probation")
+	}
+
+	// 降级之后的键值从试用段被移除
+	c.Add("myKey4", 4)
+	c.Add("myKey5", 5)
+	if got, want := fmt.Sprint(evictedKeys), "[myKey1]"; got != want {
+		t.Fatalf("got evicted keys %s; want %s", got, want)
+	}
+}
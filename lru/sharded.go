@@ -0,0 +1,193 @@
+/*
+Copyright 2013 Google Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package lru
+
+import "hash/maphash"
+
+// 默认的分片数量
+const defaultShards = 16
+
+// ShardedCache将键值按照哈希值分散到多个SafeCache，每个分片有独立的锁
+// 不同分片的键值可以并发访问，减少锁竞争，是并发安全的
+type ShardedCache struct {
+	// 缓存元素被移除的时候触发的回调函数，需要在使用缓存之前设置
+	OnEvicted func(key Key, value interface{})
+
+	shards []*SafeCache
+	mask   uint32
+}
+
+// ShardedCache结构的构造函数
+// shards会向上取整到2的幂，小于等于0时使用默认值16
+// maxEntries按照分片数量平均分配到每个分片，0 代表没有限制
+func NewSharded(maxEntries, shards int) *ShardedCache {
+	if shards <= 0 {
+		shards = defaultShards
+	}
+	n := 1
+	for n < shards {
+		n <<= 1
+	}
+
+	perShard := 0
+	if maxEntries > 0 {
+		perShard = (maxEntries + n - 1) / n
+	}
+
+	sc := &ShardedCache{
+		shards: make([]*SafeCache, n),
+		mask:   uint32(n - 1),
+	}
+	for i := range sc.shards {
+		c := New(perShard)
+		c.OnEvicted = sc.evicted
+		sc.shards[i] = ThreadSafe(c)
+	}
+	return sc
+}
+
+// 分片的回调函数，转发给用户设置的OnEvicted
+func (sc *ShardedCache) evicted(key Key, value interface{}) {
+	if sc.OnEvicted != nil {
+		sc.OnEvicted(key, value)
+	}
+}
+
+// 根据键值的哈希值选择分片
+func (sc *ShardedCache) shard(key Key) *SafeCache {
+	return sc.shards[hashKey(key)&sc.mask]
+}
+
+// FNV-1a哈希算法的参数
+const (
+	offset32 = 2166136261
+	prime32  = 16777619
+)
+
+// 其他类型的键使用的哈希种子，进程内保持不变
+var keySeed = maphash.MakeSeed()
+
+// 计算键值的哈希值，字符串和整数直接计算，其他类型使用maphash.Comparable
+// 相等的键一定得到相同的哈希值，不能使用格式化之后的字符串，例如指针会格式化成指向的内容
+func hashKey(key Key) uint32 {
+	switch k := key.(type) {
+	case string:
+		return hashString(k)
+	case int:
+		return hashUint64(uint64(k))
+	case int64:
+		return hashUint64(uint64(k))
+	case uint64:
+		return hashUint64(k)
+	case int32:
+		return hashUint64(uint64(k))
+	case uint32:
+		return hashUint64(uint64(k))
+	}
+	return uint32(maphash.Comparable(keySeed, key))
+}
+
+func hashString(s string) uint32 {
+	h := uint32(offset32)
+	for i := 0; i < len(s); i++ {
+		h ^= uint32(s[i])
+		h *= prime32
+	}
+	return h
+}
+
+func hashUint64(n uint64) uint32 {
+	h := uint32(offset32)
+	for i := 0; i < 8; i++ {
+		h ^= uint32(n & 0xff)
+		h *= prime32
+		n >>= 8
+	}
+	return h
+}
+
+// 添加键值到缓存
+func (sc *ShardedCache) Add(key Key, value interface{}) (evictedKey Key, evicted bool) {
+	return sc.shard(key).Add(key, value)
+}
+
+// 从缓存中获取键值
+func (sc *ShardedCache) Get(key Key) (value interface{}, ok bool) {
+	return sc.shard(key).Get(key)
+}
+
+// 批量获取键值，values和found与keys的顺序一一对应
+// 按照分片对键值分组，每个分片只获取一次锁
+func (sc *ShardedCache) GetMulti(keys []Key) (values []interface{}, found []bool) {
+	values = make([]interface{}, len(keys))
+	found = make([]bool, len(keys))
+
+	// 每个分片对应的键值在keys中的下标
+	groups := make(map[uint32][]int)
+	for i, key := range keys {
+		n := hashKey(key) & sc.mask
+		groups[n] = append(groups[n], i)
+	}
+	for n, idx := range groups {
+		shardKeys := make([]Key, len(idx))
+		for j, i := range idx {
+			shardKeys[j] = keys[i]
+		}
+		vs, fs := sc.shards[n].GetMulti(shardKeys)
+		for j, i := range idx {
+			values[i], found[i] = vs[j], fs[j]
+		}
+	}
+	return values, found
+}
+
+// 从缓存中获取键值，如果未命中，调用compute计算value并添加到缓存
+// compute在持有分片锁的情况下调用，不能再访问缓存
+func (sc *ShardedCache) GetOrAdd(key Key, compute func() interface{}) (value interface{}, loaded bool) {
+	return sc.shard(key).GetOrAdd(key, compute)
+}
+
+// 从缓存中移除键值
+func (sc *ShardedCache) Remove(key Key) {
+	sc.shard(key).Remove(key)
+}
+
+// 移除所有分片中pred返回true的键值，返回移除的数量
+// pred在持有分片锁的情况下调用，不能再访问缓存
+func (sc *ShardedCache) RemoveFunc(pred func(key Key, value interface{}) bool) int {
+	n := 0
+	for _, s := range sc.shards {
+		n += s.RemoveFunc(pred)
+	}
+	return n
+}
+
+// 获取所有分片的元素数量之和
+func (sc *ShardedCache) Len() int {
+	n := 0
+	for _, s := range sc.shards {
+		n += s.Len()
+	}
+	return n
+}
+
+// 重置所有分片，清除所有元素
+func (sc *ShardedCache) Clear() {
+	for _, s := range sc.shards {
+		s.Clear()
+	}
+}
@@ -0,0 +1,129 @@
+/*
+Copyright 2013 Google Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package lru
+
+import (
+	"fmt"
+	"math"
+	"sync"
+	"testing"
+)
+
+func TestShardedCache(t *testing.T) {
+	var mu sync.Mutex
+	evicted := 0
+	sc := NewSharded(64, 3)
+	sc.OnEvicted = func(key Key, value interface{}) {
+		mu.Lock()
+		evicted++
+		mu.Unlock()
+	}
+	if got := len(sc.shards); got != 4 {
+		t.Fatalf("got %d shards; want 4", got)
+	}
+
+	for i := 0; i < 32; i++ {
+		sc.Add(fmt.Sprintf("myKey%d", i), i)
+	}
+	for i := 0; i < 32; i++ {
+		if val, ok := sc.Get(fmt.Sprintf("myKey%d", i)); ok && val != i {
+			t.Fatalf("Get(myKey%d) = %v; want %d", i, val, i)
+		}
+	}
+	if got := sc.Len() + evicted; got != 32 {
+		t.Fatalf("got %d entries plus evicted; want 32", got)
+	}
+
+	sc.Remove("myKey0")
+	if _, ok := sc.Get("myKey0"); ok {
+		t.Fatal("Get returned a removed entry")
+	}
+
+	sc.Clear()
+	if got := sc.Len(); got != 0 {
+		t.Fatalf("got %d entries after Clear; want 0", got)
+	}
+}
+
+func TestShardedCacheGetMulti(t *testing.T) {
+	sc := NewSharded(0, 4)
+	keys := make([]Key, 0, 20)
+	for i := 0; i < 20; i++ {
+		if i%2 == 0 {
+			sc.Add(i, i*10)
+		}
+		keys = append(keys, i)
+	}
+
+	values, found := sc.GetMulti(keys)
+	for i := range keys {
+		if found[i] != (i%2 == 0) {
+			t.Fatalf("found[%d] = %v; want %v", i, found[i], i%2 == 0)
+		}
+		if found[i] && values[i] != i*10 {
+			t.Fatalf("values[%d] = %v; want %d", i, values[i], i*10)
+		}
+	}
+}
+
+// 哈希值和==一致，指针键按照地址选择分片，修改指向的内容不影响查找
+func TestShardedCacheKeyHash(t *testing.T) {
+	type node struct{ n int }
+	sc := NewSharded(1000, 16)
+	nodes := make([]*node, 50)
+	for i := range nodes {
+		nodes[i] = &node{i}
+		sc.Add(nodes[i], i)
+	}
+	for i, n := range nodes {
+		n.n += 1000
+		if v, ok := sc.Get(n); !ok || v != i {
+			t.Fatalf("Get(node %d) = %v, %v; want %d, true", i, v, ok, i)
+		}
+	}
+
+	negZero := math.Copysign(0, -1)
+	sc.Add(0.0, "zero")
+	if v, ok := sc.Get(negZero); !ok || v != "zero" {
+		t.Fatalf("Get(-0.0) = %v, %v; want zero, true", v, ok)
+	}
+}
+
+// 对比单个锁和分片锁在并发访问下的吞吐量
+func BenchmarkSafeCacheParallel(b *testing.B) {
+	c := ThreadSafe(New(1024))
+	b.RunParallel(func(pb *testing.PB) {
+		i := 0
+		for pb.Next() {
+			c.Add(i&2047, i)
+			c.Get(i & 2047)
+			i++
+		}
+	})
+}
+
+func BenchmarkShardedCacheParallel(b *testing.B) {
+	sc := NewSharded(1024, 0)
+	b.RunParallel(func(pb *testing.PB) {
+		i := 0
+		for pb.Next() {
+			sc.Add(i&2047, i)
+			sc.Get(i & 2047)
+			i++
+		}
+	})
+}
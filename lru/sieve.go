@@ -0,0 +1,131 @@
+/*
+Copyright 2013 Google Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package lru
+
+import "container/list"
+
+// Sieve是SIEVE算法的实现，不是并发安全的
+// 键值按照添加的顺序保存在队列中，访问时只设置访问位，不改变键值的位置
+// 移除时指针从队尾向队头移动，跳过并清除被访问过的键值，移除第一个没有被访问过的键值
+// 指针的位置在两次移除之间保持不变，到达队头之后回到队尾
+type Sieve struct {
+	// 缓存元素被移除的时候触发的回调函数
+	OnEvicted func(key Key, value interface{})
+
+	size  int
+	ll    *list.List // 队头是最新添加的键值
+	cache map[interface{}]*list.Element
+	hand  *list.Element // 下一个检查的键值，为nil时从队尾开始
+}
+
+// SIEVE算法的键值对
+type sieveEntry struct {
+	key     Key
+	value   interface{}
+	visited bool
+}
+
+// Sieve结构的构造函数，size是缓存元素的最大数量
+func NewSieve(size int) *Sieve {
+	if size <= 0 {
+		panic("lru: Sieve size must be positive")
+	}
+	return &Sieve{
+		size:  size,
+		ll:    list.New(),
+		cache: make(map[interface{}]*list.Element),
+	}
+}
+
+// 添加键值到缓存，已缓存的键值更新value并设置访问位
+func (c *Sieve) Add(key Key, value interface{}) {
+	if ele, ok := c.cache[key]; ok {
+		kv := ele.Value.(*sieveEntry)
+		kv.value = value
+		kv.visited = true
+		return
+	}
+	if c.ll.Len() >= c.size {
+		c.evict()
+	}
+	c.cache[key] = c.ll.PushFront(&sieveEntry{key: key, value: value})
+}
+
+// 移动指针找到没有被访问过的键值并移除
+func (c *Sieve) evict() {
+	e := c.hand
+	if e == nil {
+		e = c.ll.Back()
+	}
+	for e.Value.(*sieveEntry).visited {
+		e.Value.(*sieveEntry).visited = false
+		if e = e.Prev(); e == nil {
+			e = c.ll.Back()
+		}
+	}
+	c.hand = e.Prev()
+	c.removeElement(e)
+}
+
+// 从缓存中获取键值，只设置访问位
+func (c *Sieve) Get(key Key) (value interface{}, ok bool) {
+	ele, hit := c.cache[key]
+	if !hit {
+		return
+	}
+	kv := ele.Value.(*sieveEntry)
+	kv.visited = true
+	return kv.value, true
+}
+
+// 从缓存中移除键值
+func (c *Sieve) Remove(key Key) {
+	if ele, hit := c.cache[key]; hit {
+		c.removeElement(ele)
+	}
+}
+
+// 移除键值，指针指向被移除的键值时移动到前一个键值
+func (c *Sieve) removeElement(e *list.Element) {
+	if c.hand == e {
+		c.hand = e.Prev()
+	}
+	c.ll.Remove(e)
+	kv := e.Value.(*sieveEntry)
+	delete(c.cache, kv.key)
+	if c.OnEvicted != nil {
+		c.OnEvicted(kv.key, kv.value)
+	}
+}
+
+// 获取缓存的元素数量
+func (c *Sieve) Len() int {
+	return c.ll.Len()
+}
+
+// 重置缓存，清除所有元素
+func (c *Sieve) Clear() {
+	if c.OnEvicted != nil {
+		for e := c.ll.Back(); e != nil; e = e.Prev() {
+			kv := e.Value.(*sieveEntry)
+			c.OnEvicted(kv.key, kv.value)
+		}
+	}
+	c.ll.Init()
+	c.cache = make(map[interface{}]*list.Element)
+	c.hand = nil
+}
@@ -0,0 +1,55 @@
+/*
+Copyright 2013 Google Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package lru
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestSieve(t *testing.T) {
+	c := NewSieve(3)
+	evictedKeys := make([]Key, 0)
+	c.OnEvicted = func(key Key, value interface{}) {
+		evictedKeys = append(evictedKeys, key)
+	}
+	c.Add("myKey1", 1)
+	c.Add("myKey2", 2)
+	c.Add("myKey3", 3)
+
+	// myKey1被访问过，获得第二次机会
+	c.Get("myKey1")
+	c.Add("myKey4", 4)
+	if got, want := fmt.Sprint(evictedKeys), "[myKey2]"; got != want {
+		t.Fatalf("got evicted keys %s; want %s", got, want)
+	}
+
+	// 指针停留在myKey2之前的位置，继续移除myKey3和myKey4，不会回到队尾的myKey1
+	c.Add("myKey5", 5)
+	c.Add("myKey6", 6)
+	if got, want := fmt.Sprint(evictedKeys), "[myKey2 myKey3 myKey4]"; got != want {
+		t.Fatalf("got evicted keys %s; want %s", got, want)
+	}
+	if _, ok := c.Get("myKey1"); !ok {
+		t.Fatal("myKey1 was evicted")
+	}
+
+	c.Remove("myKey1")
+	if got := c.Len(); got != 2 {
+		t.Fatalf("got %d entries; want 2", got)
+	}
+}
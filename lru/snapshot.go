@@ -0,0 +1,85 @@
+/*
+Copyright 2013 Google Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package lru
+
+import (
+	"container/list"
+	"encoding/gob"
+	"fmt"
+	"io"
+	"time"
+)
+
+// 快照的数据结构，键值按照最近使用到最久未使用的顺序保存
+type snapshot struct {
+	MaxEntries int
+	Entries    []snapshotEntry
+}
+
+type snapshotEntry struct {
+	Key       interface{}
+	Value     interface{}
+	ExpiresAt time.Time
+	Cost      int64
+	Pinned    bool
+}
+
+// 使用gob将缓存的键值和MaxEntries写入w，键值的顺序保持不变
+// 键和value的具体类型需要通过gob.Register注册，否则返回错误
+func (c *Cache) SnapshotTo(w io.Writer) error {
+	snap := snapshot{MaxEntries: c.MaxEntries}
+	if c.cache != nil {
+		snap.Entries = make([]snapshotEntry, 0, c.ll.Len())
+		for e := c.ll.Front(); e != nil; e = e.Next() {
+			kv := e.Value.(*entry)
+			snap.Entries = append(snap.Entries, snapshotEntry{kv.key, kv.value, kv.expiresAt, kv.cost, kv.pinned})
+		}
+	}
+	if err := gob.NewEncoder(w).Encode(&snap); err != nil {
+		return fmt.Errorf("lru: encoding snapshot (are the key and value types registered with gob.Register?): %v", err)
+	}
+	return nil
+}
+
+// 从r读取SnapshotTo写入的快照，替换缓存中已有的键值
+// 恢复之后键值的顺序和MaxEntries与快照一致，超出MaxEntries的键值从最久未使用的一端丢弃
+func (c *Cache) LoadFrom(r io.Reader) error {
+	var snap snapshot
+	if err := gob.NewDecoder(r).Decode(&snap); err != nil {
+		return fmt.Errorf("lru: decoding snapshot: %v", err)
+	}
+
+	c.Clear()
+	c.MaxEntries = snap.MaxEntries
+	c.ll = list.New()
+	c.cache = make(map[interface{}]*list.Element, len(snap.Entries))
+
+	entries := snap.Entries
+	if c.MaxEntries != 0 && len(entries) > c.MaxEntries {
+		entries = entries[:c.MaxEntries]
+	}
+	// 从最久未使用的键值开始添加到最前面，恢复原来的顺序
+	for i := len(entries) - 1; i >= 0; i-- {
+		se := entries[i]
+		kv := &entry{key: se.Key, value: se.Value, expiresAt: se.ExpiresAt, cost: se.Cost, pinned: se.Pinned}
+		if c.Sizer != nil {
+			kv.size = c.Sizer(kv.key, kv.value)
+		}
+		c.insert(kv)
+	}
+	return nil
+}
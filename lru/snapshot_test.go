@@ -0,0 +1,81 @@
+/*
+Copyright 2013 Google Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package lru
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestSnapshotRoundTrip(t *testing.T) {
+	lru := New(3)
+	lru.Add("myKey1", 1)
+	lru.Add("myKey2", "two")
+	lru.Add("myKey3", 3.0)
+	lru.Get("myKey1")
+
+	var buf bytes.Buffer
+	if err := lru.SnapshotTo(&buf); err != nil {
+		t.Fatalf("SnapshotTo: %v", err)
+	}
+
+	restored := New(0)
+	if err := restored.LoadFrom(&buf); err != nil {
+		t.Fatalf("LoadFrom: %v", err)
+	}
+	if restored.MaxEntries != 3 {
+		t.Fatalf("got MaxEntries %d; want 3", restored.MaxEntries)
+	}
+	if got, want := restored.String(), lru.String(); got != want {
+		t.Fatalf("got restored cache %s; want %s", got, want)
+	}
+}
+
+func TestSnapshotTruncate(t *testing.T) {
+	lru := New(0)
+	for i := 0; i < 5; i++ {
+		lru.Add(i, i)
+	}
+	// 快照中的MaxEntries小于键值的数量
+	lru.MaxEntries = 2
+	var buf bytes.Buffer
+	if err := lru.SnapshotTo(&buf); err != nil {
+		t.Fatalf("SnapshotTo: %v", err)
+	}
+	restored := New(0)
+	if err := restored.LoadFrom(&buf); err != nil {
+		t.Fatalf("LoadFrom: %v", err)
+	}
+
+	// 丢弃最久未使用的键值
+	if got, want := fmt.Sprint(restored.Keys()), "[4 3]"; got != want {
+		t.Fatalf("got keys %s; want %s", got, want)
+	}
+}
+
+type unregistered struct{ N int }
+
+func TestSnapshotUnregistered(t *testing.T) {
+	lru := New(0)
+	lru.Add("myKey", unregistered{1})
+	err := lru.SnapshotTo(&bytes.Buffer{})
+	if err == nil || !strings.Contains(err.Error(), "gob.Register") {
+		t.Fatalf("got error %v; want an error mentioning gob.Register", err)
+	}
+}
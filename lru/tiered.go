@@ -0,0 +1,93 @@
+/*
+Copyright 2013 Google Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package lru
+
+// Store是Tiered的二级缓存需要实现的接口
+// TwoQueue、ARC、LFU、Sieve、Segmented和TinyLFU都实现了Store，Cache可以通过CacheStore包装
+type Store interface {
+	Add(key Key, value interface{})
+	Get(key Key) (value interface{}, ok bool)
+	Remove(key Key)
+	Len() int
+}
+
+// 将Cache包装为Store
+func CacheStore(c *Cache) Store {
+	return cacheStore{c}
+}
+
+type cacheStore struct {
+	*Cache
+}
+
+func (s cacheStore) Add(key Key, value interface{}) {
+	s.Cache.Add(key, value)
+}
+
+// Tiered是两级缓存，不是并发安全的
+// 一级缓存因为超出容量限制移除的键值不会被丢弃，而是添加到二级缓存
+// 二级缓存命中的键值重新添加到一级缓存
+type Tiered struct {
+	l1 *Cache
+	l2 Store
+}
+
+// Tiered结构的构造函数，l1是一级缓存，l2是二级缓存
+// 会替换l1的OnEvictedWithReason，需要观察最终被丢弃的键值时，设置l2的回调函数
+func NewTiered(l1 *Cache, l2 Store) *Tiered {
+	t := &Tiered{l1: l1, l2: l2}
+	l1.OnEvicted = nil
+	l1.OnEvictedWithReason = t.spill
+	return t
+}
+
+// 一级缓存超出容量限制移除的键值添加到二级缓存，主动移除或者过期的键值直接丢弃
+func (t *Tiered) spill(key Key, value interface{}, reason EvictReason) {
+	if reason == ReasonCapacity {
+		t.l2.Add(key, value)
+	}
+}
+
+// 添加键值到一级缓存，同时移除二级缓存中旧的value
+func (t *Tiered) Add(key Key, value interface{}) {
+	t.l2.Remove(key)
+	t.l1.Add(key, value)
+}
+
+// 从缓存中获取键值，二级缓存命中时将键值移回一级缓存
+func (t *Tiered) Get(key Key) (value interface{}, ok bool) {
+	if value, ok = t.l1.Get(key); ok {
+		return value, true
+	}
+	if value, ok = t.l2.Get(key); !ok {
+		return nil, false
+	}
+	t.l2.Remove(key)
+	t.l1.Add(key, value)
+	return value, true
+}
+
+// 从两级缓存中移除键值
+func (t *Tiered) Remove(key Key) {
+	t.l1.Remove(key)
+	t.l2.Remove(key)
+}
+
+// 获取两级缓存的元素数量之和
+func (t *Tiered) Len() int {
+	return t.l1.Len() + t.l2.Len()
+}
@@ -0,0 +1,51 @@
+/*
+Copyright 2013 Google Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package lru
+
+import "testing"
+
+func TestTiered(t *testing.T) {
+	l2 := New(10)
+	c := NewTiered(New(2), CacheStore(l2))
+	c.Add("myKey1", 1)
+	c.Add("myKey2", 2)
+	c.Add("myKey3", 3)
+
+	// myKey1从一级缓存移除之后进入二级缓存
+	if _, ok := l2.Peek("myKey1"); !ok {
+		t.Fatal("myKey1 was not spilled to L2")
+	}
+	if got := c.Len(); got != 3 {
+		t.Fatalf("got %d entries; want 3", got)
+	}
+
+	// 二级缓存命中之后移回一级缓存，一级缓存中最老的myKey2进入二级缓存
+	if val, ok := c.Get("myKey1"); !ok || val != 1 {
+		t.Fatalf("Get = %v, %v; want 1, true", val, ok)
+	}
+	if _, ok := l2.Peek("myKey1"); ok {
+		t.Fatal("myKey1 was not promoted out of L2")
+	}
+	if _, ok := l2.Peek("myKey2"); !ok {
+		t.Fatal("myKey2 was not spilled to L2")
+	}
+
+	c.Remove("myKey2")
+	if got := c.Len(); got != 2 {
+		t.Fatalf("got %d entries after Remove; want 2", got)
+	}
+}
@@ -0,0 +1,271 @@
+/*
+Copyright 2013 Google Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package lru
+
+import "container/list"
+
+// W-TinyLFU默认的参数
+const (
+	// 窗口LRU占总容量的比例
+	defaultWindowRatio = 0.01
+	// 主缓存中保护段占的比例，和Segmented相同
+	defaultProtectedRatio = 0.8
+	// 频率计数器的最大值，计数器只使用4位
+	maxSketchCount = 15
+)
+
+// TinyLFU是W-TinyLFU算法的实现，不是并发安全的
+// 新键值先进入窗口LRU，从窗口移出的键值作为候选者和主缓存的淘汰者比较访问频率
+// 只有候选者的估计频率高于淘汰者时才进入主缓存，否则候选者被移除
+// 主缓存是分段LRU，试用段中再次被访问的键值晋升到保护段
+// 访问频率由count-min sketch估计，计数器定期减半，使频率能够反映最近的访问
+type TinyLFU struct {
+	// 缓存元素被移除的时候触发的回调函数，包括没有被准入的候选者
+	OnEvicted func(key Key, value interface{})
+
+	windowSz    int // 窗口LRU的最大数量
+	mainSz      int // 主缓存的最大数量
+	protectedSz int // 保护段的最大数量
+
+	window    *list.List
+	probation *list.List
+	protected *list.List
+	cache     map[interface{}]*list.Element
+
+	sketch *countMinSketch
+}
+
+// W-TinyLFU的键值对，记录所在的队列
+type tinyLFUEntry struct {
+	key   Key
+	value interface{}
+	list  *list.List
+}
+
+// TinyLFU结构的构造函数，使用默认的窗口比例和sketch宽度
+func NewTinyLFU(size int) *TinyLFU {
+	return NewTinyLFUParams(size, defaultWindowRatio, 0)
+}
+
+// TinyLFU结构的构造函数，指定窗口LRU占总容量的比例和sketch每一行计数器的数量
+// sketchWidth会向上取整到2的幂，小于等于0时使用size
+func NewTinyLFUParams(size int, windowRatio float64, sketchWidth int) *TinyLFU {
+	if size <= 0 {
+		panic("lru: TinyLFU size must be positive")
+	}
+	windowSz := int(float64(size) * windowRatio)
+	if windowSz < 1 {
+		windowSz = 1
+	}
+	if windowSz > size {
+		windowSz = size
+	}
+	mainSz := size - windowSz
+	if sketchWidth <= 0 {
+		sketchWidth = size
+	}
+	return &TinyLFU{
+		windowSz:    windowSz,
+		mainSz:      mainSz,
+		protectedSz: int(float64(mainSz) * defaultProtectedRatio),
+		window:      list.New(),
+		probation:   list.New(),
+		protected:   list.New(),
+		cache:       make(map[interface{}]*list.Element),
+		sketch:      newCountMinSketch(sketchWidth, 10*size),
+	}
+}
+
+// 添加键值到缓存，已缓存的键值更新value，和Get一样视为一次访问
+func (c *TinyLFU) Add(key Key, value interface{}) {
+	c.sketch.increment(key)
+	if ele, ok := c.cache[key]; ok {
+		ele.Value.(*tinyLFUEntry).value = value
+		c.access(ele)
+		return
+	}
+
+	c.cache[key] = c.window.PushFront(&tinyLFUEntry{key, value, c.window})
+	if c.window.Len() > c.windowSz {
+		c.admit(c.window.Back())
+	}
+}
+
+// 候选者从窗口移出，根据访问频率决定进入主缓存还是被移除
+func (c *TinyLFU) admit(candidate *list.Element) {
+	kv := candidate.Value.(*tinyLFUEntry)
+	c.window.Remove(candidate)
+	if c.probation.Len()+c.protected.Len() >= c.mainSz {
+		victim := c.probation.Back()
+		if victim == nil {
+			victim = c.protected.Back()
+		}
+		if victim == nil || c.sketch.estimate(kv.key) <= c.sketch.estimate(victim.Value.(*tinyLFUEntry).key) {
+			delete(c.cache, kv.key)
+			c.evicted(kv)
+			return
+		}
+		c.removeElement(victim)
+	}
+	kv.list = c.probation
+	c.cache[kv.key] = c.probation.PushFront(kv)
+}
+
+// 从缓存中获取键值
+func (c *TinyLFU) Get(key Key) (value interface{}, ok bool) {
+	c.sketch.increment(key)
+	ele, hit := c.cache[key]
+	if !hit {
+		return
+	}
+	c.access(ele)
+	return ele.Value.(*tinyLFUEntry).value, true
+}
+
+// 键值被访问，试用段的键值晋升到保护段，保护段超出限制时最老的键值降级到试用段
+func (c *TinyLFU) access(ele *list.Element) {
+	kv := ele.Value.(*tinyLFUEntry)
+	if kv.list != c.probation || c.protectedSz == 0 {
+		kv.list.MoveToFront(ele)
+		return
+	}
+	c.probation.Remove(ele)
+	kv.list = c.protected
+	c.cache[kv.key] = c.protected.PushFront(kv)
+	if c.protected.Len() > c.protectedSz {
+		demoted := c.protected.Back()
+		dkv := demoted.Value.(*tinyLFUEntry)
+		c.protected.Remove(demoted)
+		dkv.list = c.probation
+		c.cache[dkv.key] = c.probation.PushFront(dkv)
+	}
+}
+
+// 从缓存中移除键值
+func (c *TinyLFU) Remove(key Key) {
+	if ele, hit := c.cache[key]; hit {
+		c.removeElement(ele)
+	}
+}
+
+// 从所在的队列中移除键值
+func (c *TinyLFU) removeElement(e *list.Element) {
+	kv := e.Value.(*tinyLFUEntry)
+	kv.list.Remove(e)
+	delete(c.cache, kv.key)
+	c.evicted(kv)
+}
+
+func (c *TinyLFU) evicted(kv *tinyLFUEntry) {
+	if c.OnEvicted != nil {
+		c.OnEvicted(kv.key, kv.value)
+	}
+}
+
+// 获取缓存的元素数量
+func (c *TinyLFU) Len() int {
+	return len(c.cache)
+}
+
+// 重置缓存，清除所有元素和访问频率
+func (c *TinyLFU) Clear() {
+	if c.OnEvicted != nil {
+		for _, e := range c.cache {
+			c.evicted(e.Value.(*tinyLFUEntry))
+		}
+	}
+	c.window.Init()
+	c.probation.Init()
+	c.protected.Init()
+	c.cache = make(map[interface{}]*list.Element)
+	c.sketch.clear()
+}
+
+// count-min sketch，使用4行计数器估计键的访问频率
+// 每累计resetAt次访问，所有计数器减半
+type countMinSketch struct {
+	rows      [4][]uint8
+	mask      uint32
+	additions int
+	resetAt   int
+}
+
+func newCountMinSketch(width, resetAt int) *countMinSketch {
+	n := 1
+	for n < width {
+		n <<= 1
+	}
+	s := &countMinSketch{mask: uint32(n - 1), resetAt: resetAt}
+	for i := range s.rows {
+		s.rows[i] = make([]uint8, n)
+	}
+	return s
+}
+
+// 计算键在每一行中的位置，每一行使用哈希值的不同部分
+func (s *countMinSketch) indexes(key Key) [4]uint32 {
+	h := uint64(hashKey(key)) * 0x9e3779b97f4a7c15
+	var idx [4]uint32
+	for i := range idx {
+		idx[i] = uint32(h>>(16*uint(i))) & s.mask
+		h = h*0x9e3779b97f4a7c15 + uint64(i)
+	}
+	return idx
+}
+
+// 增加键的访问次数
+func (s *countMinSketch) increment(key Key) {
+	for i, j := range s.indexes(key) {
+		if s.rows[i][j] < maxSketchCount {
+			s.rows[i][j]++
+		}
+	}
+	s.additions++
+	if s.additions >= s.resetAt {
+		s.reset()
+	}
+}
+
+// 估计键的访问次数，取所有行中的最小值
+func (s *countMinSketch) estimate(key Key) int {
+	count := uint8(maxSketchCount)
+	for i, j := range s.indexes(key) {
+		if s.rows[i][j] < count {
+			count = s.rows[i][j]
+		}
+	}
+	return int(count)
+}
+
+// 所有计数器减半，让旧的访问频率逐渐衰减
+func (s *countMinSketch) reset() {
+	for i := range s.rows {
+		for j := range s.rows[i] {
+			s.rows[i][j] >>= 1
+		}
+	}
+	s.additions /= 2
+}
+
+func (s *countMinSketch) clear() {
+	for i := range s.rows {
+		for j := range s.rows[i] {
+			s.rows[i][j] = 0
+		}
+	}
+	s.additions = 0
+}
@@ -0,0 +1,85 @@
+/*
+Copyright 2013 Google Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package lru
+
+import (
+	"fmt"
+	"testing"
+)
+
+// 经常访问的键值不会因为扫描冷数据而被移除
+func TestTinyLFUHotSurvivesScan(t *testing.T) {
+	c := NewTinyLFU(100)
+	for i := 0; i < 20; i++ {
+		key := fmt.Sprintf("hot%d", i)
+		c.Add(key, i)
+		for j := 0; j < 5; j++ {
+			c.Get(key)
+		}
+	}
+
+	for i := 0; i < 1000; i++ {
+		c.Add(fmt.Sprintf("cold%d", i), i)
+	}
+	for i := 0; i < 20; i++ {
+		if _, ok := c.Get(fmt.Sprintf("hot%d", i)); !ok {
+			t.Fatalf("hot%d was evicted by a cold scan", i)
+		}
+	}
+	if got := c.Len(); got > 100 {
+		t.Fatalf("got %d entries; want at most 100", got)
+	}
+}
+
+// 没有被准入的候选者也会触发回调函数
+func TestTinyLFURejectCandidate(t *testing.T) {
+	c := NewTinyLFUParams(2, 0.5, 0)
+	evictedKeys := make([]Key, 0)
+	c.OnEvicted = func(key Key, value interface{}) {
+		evictedKeys = append(evictedKeys, key)
+	}
+	c.Add("hot", 1)
+	c.Get("hot")
+	c.Get("hot")
+	c.Add("myKey1", 2)
+	c.Add("myKey2", 3)
+
+	if got, want := fmt.Sprint(evictedKeys), "[myKey1]"; got != want {
+		t.Fatalf("got evicted keys %s; want %s", got, want)
+	}
+	if _, ok := c.Get("hot"); !ok {
+		t.Fatal("hot was evicted")
+	}
+}
+
+func TestCountMinSketchAging(t *testing.T) {
+	s := newCountMinSketch(64, 20)
+	for i := 0; i < 10; i++ {
+		s.increment("myKey")
+	}
+	if got := s.estimate("myKey"); got != 10 {
+		t.Fatalf("got estimate %d; want 10", got)
+	}
+
+	// 累计20次访问之后计数器减半
+	for i := 0; i < 10; i++ {
+		s.increment(i)
+	}
+	if got := s.estimate("myKey"); got != 5 {
+		t.Fatalf("got estimate %d after aging; want 5", got)
+	}
+}
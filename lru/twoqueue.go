@@ -0,0 +1,184 @@
+/*
+Copyright 2013 Google Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package lru
+
+import "container/list"
+
+// 2Q算法默认的队列比例
+const (
+	// A1in队列占总容量的比例
+	defaultRecentRatio = 0.25
+	// A1out幽灵队列占总容量的比例
+	defaultGhostRatio = 0.5
+)
+
+// TwoQueue是2Q算法的实现，不是并发安全的
+// 新键值先进入A1in队列，从A1in移除的键值只保留键在A1out幽灵队列
+// 键值在A1out中时再次添加会进入Am队列，Am按照LRU移除
+// 只访问一次的键值很快会被移除，不会因为扫描冷数据而移除Am中的热数据
+type TwoQueue struct {
+	// 缓存元素被移除的时候触发的回调函数，移除幽灵键不会触发
+	OnEvicted func(key Key, value interface{})
+
+	size     int // 缓存元素的最大数量
+	recentSz int // A1in队列的最大数量
+	ghostSz  int // A1out队列的最大数量
+
+	recent   *list.List // A1in队列，先进先出
+	frequent *list.List // Am队列，最近最少使用
+	ghost    *list.List // A1out队列，只保存键
+
+	cache  map[interface{}]*list.Element // A1in和Am中的键值
+	ghosts map[interface{}]*list.Element // A1out中的键
+}
+
+// 2Q算法的键值对，记录所在的队列
+type twoQueueEntry struct {
+	key   Key
+	value interface{}
+	list  *list.List
+}
+
+// TwoQueue结构的构造函数，使用默认的队列比例
+func NewTwoQueue(size int) *TwoQueue {
+	return NewTwoQueueParams(size, defaultRecentRatio, defaultGhostRatio)
+}
+
+// TwoQueue结构的构造函数，指定A1in和A1out队列占总容量的比例
+func NewTwoQueueParams(size int, recentRatio, ghostRatio float64) *TwoQueue {
+	if size <= 0 {
+		panic("lru: TwoQueue size must be positive")
+	}
+	recentSz := int(float64(size) * recentRatio)
+	if recentSz < 1 {
+		recentSz = 1
+	}
+	ghostSz := int(float64(size) * ghostRatio)
+	if ghostSz < 1 {
+		ghostSz = 1
+	}
+	return &TwoQueue{
+		size:     size,
+		recentSz: recentSz,
+		ghostSz:  ghostSz,
+		recent:   list.New(),
+		frequent: list.New(),
+		ghost:    list.New(),
+		cache:    make(map[interface{}]*list.Element),
+		ghosts:   make(map[interface{}]*list.Element),
+	}
+}
+
+// 添加键值到缓存
+func (c *TwoQueue) Add(key Key, value interface{}) {
+	// 如果键值已缓存，更新value，Am中的键值移动到最前面，A1in中的键值位置不变
+	if ele, ok := c.cache[key]; ok {
+		kv := ele.Value.(*twoQueueEntry)
+		kv.value = value
+		if kv.list == c.frequent {
+			c.frequent.MoveToFront(ele)
+		}
+		return
+	}
+
+	// 如果键在A1out中，说明键值被再次访问，放入Am队列
+	if ele, ok := c.ghosts[key]; ok {
+		c.ghost.Remove(ele)
+		delete(c.ghosts, key)
+		c.reclaim()
+		c.cache[key] = c.frequent.PushFront(&twoQueueEntry{key, value, c.frequent})
+		return
+	}
+
+	// 新键值放入A1in队列
+	c.reclaim()
+	c.cache[key] = c.recent.PushFront(&twoQueueEntry{key, value, c.recent})
+}
+
+// 缓存已满时腾出一个位置
+// A1in超出限制时将最老的键值移到A1out，否则移除Am中最近没有使用的键值
+func (c *TwoQueue) reclaim() {
+	if c.Len() < c.size {
+		return
+	}
+	if c.recent.Len() > c.recentSz || c.frequent.Len() == 0 {
+		kv := c.removeElement(c.recent.Back())
+		c.ghosts[kv.key] = c.ghost.PushFront(kv.key)
+		if c.ghost.Len() > c.ghostSz {
+			ele := c.ghost.Back()
+			c.ghost.Remove(ele)
+			delete(c.ghosts, ele.Value)
+		}
+		return
+	}
+	c.removeElement(c.frequent.Back())
+}
+
+// 从缓存中获取键值，只有Am中的键值会移动到最前面
+func (c *TwoQueue) Get(key Key) (value interface{}, ok bool) {
+	ele, hit := c.cache[key]
+	if !hit {
+		return
+	}
+	kv := ele.Value.(*twoQueueEntry)
+	if kv.list == c.frequent {
+		c.frequent.MoveToFront(ele)
+	}
+	return kv.value, true
+}
+
+// 从缓存中移除键值，同时清除A1out中的键
+func (c *TwoQueue) Remove(key Key) {
+	if ele, hit := c.cache[key]; hit {
+		c.removeElement(ele)
+	}
+	if ele, hit := c.ghosts[key]; hit {
+		c.ghost.Remove(ele)
+		delete(c.ghosts, key)
+	}
+}
+
+// 从所在的队列中移除键值
+func (c *TwoQueue) removeElement(e *list.Element) *twoQueueEntry {
+	kv := e.Value.(*twoQueueEntry)
+	kv.list.Remove(e)
+	delete(c.cache, kv.key)
+	if c.OnEvicted != nil {
+		c.OnEvicted(kv.key, kv.value)
+	}
+	return kv
+}
+
+// 获取缓存的元素数量，不包括A1out中的键
+func (c *TwoQueue) Len() int {
+	return c.recent.Len() + c.frequent.Len()
+}
+
+// 重置缓存，清除所有元素
+func (c *TwoQueue) Clear() {
+	if c.OnEvicted != nil {
+		for _, e := range c.cache {
+			kv := e.Value.(*twoQueueEntry)
+			c.OnEvicted(kv.key, kv.value)
+		}
+	}
+	c.recent.Init()
+	c.frequent.Init()
+	c.ghost.Init()
+	c.cache = make(map[interface{}]*list.Element)
+	c.ghosts = make(map[interface{}]*list.Element)
+}
@@ -0,0 +1,83 @@
+/*
+Copyright 2013 Google Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package lru
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestTwoQueueOneHitEvicted(t *testing.T) {
+	c := NewTwoQueue(8)
+	c.Add("once", 1234)
+
+	// 只访问一次的键值在A1in中很快被移除
+	for i := 0; i < 8; i++ {
+		c.Add(fmt.Sprintf("cold%d", i), i)
+	}
+	if _, ok := c.Get("once"); ok {
+		t.Fatal("one-hit key survived a cold scan")
+	}
+	if got := c.Len(); got != 8 {
+		t.Fatalf("got %d entries; want 8", got)
+	}
+}
+
+func TestTwoQueueHotSurvivesScan(t *testing.T) {
+	c := NewTwoQueue(8)
+	c.Add("hot", 1234)
+	for i := 0; i < 8; i++ {
+		c.Add(fmt.Sprintf("warm%d", i), i)
+	}
+
+	// hot在A1out中，再次添加时进入Am
+	if _, ok := c.Get("hot"); ok {
+		t.Fatal("hot key should have been moved to the ghost queue")
+	}
+	c.Add("hot", 1234)
+
+	// 大量冷数据扫描不会移除Am中的键值
+	for i := 0; i < 100; i++ {
+		c.Add(fmt.Sprintf("cold%d", i), i)
+	}
+	if val, ok := c.Get("hot"); !ok || val != 1234 {
+		t.Fatalf("Get(hot) = %v, %v; want 1234, true", val, ok)
+	}
+	if got := c.Len(); got > 8 {
+		t.Fatalf("got %d entries; want at most 8", got)
+	}
+}
+
+func TestTwoQueueRemove(t *testing.T) {
+	evicted := 0
+	c := NewTwoQueue(4)
+	c.OnEvicted = func(key Key, value interface{}) { evicted++ }
+	c.Add("myKey", 1234)
+	c.Remove("myKey")
+	if _, ok := c.Get("myKey"); ok {
+		t.Fatal("Get returned a removed entry")
+	}
+	if evicted != 1 {
+		t.Fatalf("got %d evictions; want 1", evicted)
+	}
+
+	c.Add("myKey", 1234)
+	c.Clear()
+	if got := c.Len(); got != 0 {
+		t.Fatalf("got %d entries after Clear; want 0", got)
+	}
+}
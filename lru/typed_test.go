@@ -0,0 +1,87 @@
+/*
+Copyright 2013 Google Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package lru
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestTypedGet(t *testing.T) {
+	lru := NewTyped[string, int](0)
+	lru.Add("myKey", 1234)
+	if val, ok := lru.Get("myKey"); !ok || val != 1234 {
+		t.Fatalf("Get = %v, %v; want 1234, true", val, ok)
+	}
+	if val, ok := lru.Get("nonsense"); ok || val != 0 {
+		t.Fatalf("Get = %v, %v; want 0, false", val, ok)
+	}
+
+	lru.Remove("myKey")
+	if _, ok := lru.Get("myKey"); ok {
+		t.Fatal("Get returned a removed entry")
+	}
+}
+
+func TestTypedEvict(t *testing.T) {
+	evictedKeys := make([]string, 0)
+	lru := NewTyped[string, int](20)
+	lru.OnEvicted = func(key string, value int) {
+		evictedKeys = append(evictedKeys, key)
+	}
+	for i := 0; i < 22; i++ {
+		lru.Add(fmt.Sprintf("myKey%d", i), i)
+	}
+
+	if len(evictedKeys) != 2 {
+		t.Fatalf("got %d evicted keys; want 2", len(evictedKeys))
+	}
+	if evictedKeys[0] != "myKey0" || evictedKeys[1] != "myKey1" {
+		t.Fatalf("got evicted keys %v; want [myKey0 myKey1]", evictedKeys)
+	}
+
+	key, val, ok := lru.RemoveOldest()
+	if !ok || key != "myKey2" || val != 2 {
+		t.Fatalf("RemoveOldest = %v, %v, %v; want myKey2, 2, true", key, val, ok)
+	}
+
+	lru.Clear()
+	if got := lru.Len(); got != 0 {
+		t.Fatalf("got %d entries after Clear; want 0", got)
+	}
+}
+
+// 对比Cache和TypedCache添加和获取键值的内存分配
+func BenchmarkCacheAddGet(b *testing.B) {
+	b.ReportAllocs()
+	lru := New(1024)
+	for i := 0; i < b.N; i++ {
+		lru.Add(i&2047, i)
+		if v, ok := lru.Get(i & 2047); ok {
+			_ = v.(int)
+		}
+	}
+}
+
+func BenchmarkTypedCacheAddGet(b *testing.B) {
+	b.ReportAllocs()
+	lru := NewTyped[int, int](1024)
+	for i := 0; i < b.N; i++ {
+		lru.Add(i&2047, i)
+		lru.Get(i & 2047)
+	}
+}
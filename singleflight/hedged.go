@@ -0,0 +1,66 @@
+/*
+Copyright 2012 Google Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package singleflight
+
+import (
+	"runtime/debug"
+	"time"
+)
+
+// 和Do相同，但是fn在hedgeAfter之后还没有完成时再执行一次fn，使用先完成的结果
+// 等待的调用者同样得到先完成的结果，较慢的结果被丢弃
+// 用额外的执行次数换取更低的尾延迟，fn需要可以并发执行，而且两次执行的结果可以互相替代
+func (g *Group) DoHedged(key string, hedgeAfter time.Duration, fn func() (interface{}, error)) (interface{}, error) {
+	return g.Do(key, func() (interface{}, error) {
+		return hedge(hedgeAfter, fn)
+	})
+}
+
+// 执行fn，超过after还没有完成时再执行一次，返回先完成的结果
+func hedge(after time.Duration, fn func() (interface{}, error)) (interface{}, error) {
+	// 缓冲区可以容纳两次执行的结果，较慢的执行不会阻塞
+	ch := make(chan Result, 2)
+	run := func() {
+		normalReturn := false
+		defer func() {
+			if normalReturn {
+				return
+			}
+			if r := recover(); r != nil {
+				ch <- Result{Err: &PanicError{Value: r, Stack: debug.Stack()}}
+			} else {
+				ch <- Result{Err: errGoexit}
+			}
+		}()
+		v, err := fn()
+		normalReturn = true
+		ch <- Result{Val: v, Err: err}
+	}
+
+	go run()
+	timer := time.NewTimer(after)
+	defer timer.Stop()
+	select {
+	case res := <-ch:
+		return res.Val, res.Err
+	case <-timer.C:
+	}
+
+	go run()
+	res := <-ch
+	return res.Val, res.Err
+}
@@ -0,0 +1,74 @@
+/*
+Copyright 2012 Google Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package singleflight
+
+import (
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// 第一次执行很慢时，再次执行fn并返回较快的结果
+func TestDoHedged(t *testing.T) {
+	var g Group
+	slow := make(chan struct{})
+	defer close(slow)
+	var calls int32
+	fn := func() (interface{}, error) {
+		if atomic.AddInt32(&calls, 1) == 1 {
+			<-slow
+			return "slow", nil
+		}
+		return "fast", nil
+	}
+
+	waiter := make(chan interface{})
+	go func() {
+		for g.InFlight() == 0 {
+			time.Sleep(time.Millisecond)
+		}
+		v, _ := g.Do("key", fn)
+		waiter <- v
+	}()
+
+	v, err := g.DoHedged("key", 10*time.Millisecond, fn)
+	if v != "fast" || err != nil {
+		t.Errorf("DoHedged = %v, %v; want fast, nil", v, err)
+	}
+	if got := <-waiter; got != "fast" {
+		t.Errorf("waiter got %v; want fast", got)
+	}
+	if got := atomic.LoadInt32(&calls); got != 2 {
+		t.Errorf("fn called %d times; want 2", got)
+	}
+}
+
+// 第一次执行在hedgeAfter之前完成时不会再次执行
+func TestDoHedgedFast(t *testing.T) {
+	var g Group
+	var calls int32
+	v, err := g.DoHedged("key", time.Hour, func() (interface{}, error) {
+		atomic.AddInt32(&calls, 1)
+		return "bar", nil
+	})
+	if v != "bar" || err != nil {
+		t.Errorf("DoHedged = %v, %v; want bar, nil", v, err)
+	}
+	if got := atomic.LoadInt32(&calls); got != 1 {
+		t.Errorf("fn called %d times; want 1", got)
+	}
+}
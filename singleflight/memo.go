@@ -0,0 +1,143 @@
+/*
+Copyright 2012 Google Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package singleflight
+
+import (
+	"sync"
+	"time"
+)
+
+// GroupCache在Group的基础上缓存请求完成之后的结果
+// 结果在TTL内被后续的Do直接返回，不会再次执行fn，错误结果使用ErrTTL
+// 过期的结果在下一次访问同一个key时删除，key很多且不会重复访问时需要定期调用Forget
+type GroupCache struct {
+	// 成功结果的缓存时间，0 代表不缓存
+	TTL time.Duration
+	// 错误结果的缓存时间，0 代表不缓存
+	ErrTTL time.Duration
+
+	g Group
+
+	mu      sync.Mutex
+	results map[string]memoResult
+	// 正在执行fn的key，Forget通过增加代数让执行中的fn不再保存结果
+	flights map[string]*memoFlight
+
+	// 获取当前时间，为nil时使用time.Now，测试时可以替换
+	now func() time.Time
+}
+
+// 缓存的结果和过期时间
+type memoResult struct {
+	val       interface{}
+	err       error
+	expiresAt time.Time
+}
+
+// 执行中的fn的数量和key的代数
+type memoFlight struct {
+	n   int
+	gen uint64
+}
+
+// 和Group.Do相同，但是TTL内的后续请求直接返回缓存的结果
+// fn发生panic时结果不会被缓存
+func (m *GroupCache) Do(key string, fn func() (interface{}, error)) (interface{}, error) {
+	m.mu.Lock()
+	if r, ok := m.results[key]; ok {
+		if m.timeNow().Before(r.expiresAt) {
+			m.mu.Unlock()
+			return r.val, r.err
+		}
+		delete(m.results, key)
+	}
+	m.mu.Unlock()
+
+	return m.g.Do(key, func() (interface{}, error) {
+		gen := m.begin(key)
+		defer m.end(key)
+		val, err := fn()
+		m.store(key, gen, val, err)
+		return val, err
+	})
+}
+
+// 记录key开始执行fn，返回key当前的代数
+func (m *GroupCache) begin(key string) uint64 {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	f := m.flights[key]
+	if f == nil {
+		if m.flights == nil {
+			m.flights = make(map[string]*memoFlight)
+		}
+		f = new(memoFlight)
+		m.flights[key] = f
+	}
+	f.n++
+	return f.gen
+}
+
+// 记录key的fn执行结束，没有执行中的fn时删除记录
+func (m *GroupCache) end(key string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if f := m.flights[key]; f != nil {
+		if f.n--; f.n == 0 {
+			delete(m.flights, key)
+		}
+	}
+}
+
+// 按照结果是否出错选择缓存时间，保存结果
+// fn执行期间调用过Forget时，结果已经过时，不保存
+func (m *GroupCache) store(key string, gen uint64, val interface{}, err error) {
+	ttl := m.TTL
+	if err != nil {
+		ttl = m.ErrTTL
+	}
+	if ttl <= 0 {
+		return
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if f := m.flights[key]; f == nil || f.gen != gen {
+		return
+	}
+	if m.results == nil {
+		m.results = make(map[string]memoResult)
+	}
+	m.results[key] = memoResult{val, err, m.timeNow().Add(ttl)}
+}
+
+// 删除key缓存的结果，并忘记执行中的请求，之后的请求会重新执行fn
+func (m *GroupCache) Forget(key string) {
+	m.mu.Lock()
+	delete(m.results, key)
+	if f := m.flights[key]; f != nil {
+		f.gen++
+	}
+	m.mu.Unlock()
+	m.g.Forget(key)
+}
+
+func (m *GroupCache) timeNow() time.Time {
+	if m.now != nil {
+		return m.now()
+	}
+	return time.Now()
+}
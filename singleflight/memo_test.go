@@ -0,0 +1,106 @@
+/*
+Copyright 2012 Google Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package singleflight
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestGroupCache(t *testing.T) {
+	now := time.Unix(0, 0)
+	m := &GroupCache{TTL: time.Minute, ErrTTL: time.Second}
+	m.now = func() time.Time { return now }
+
+	calls := 0
+	fn := func() (interface{}, error) {
+		calls++
+		return calls, nil
+	}
+
+	// TTL内返回缓存的结果
+	m.Do("key", fn)
+	now = now.Add(30 * time.Second)
+	if v, _ := m.Do("key", fn); v != 1 {
+		t.Errorf("Do within TTL = %v; want 1", v)
+	}
+
+	// 过期之后重新执行
+	now = now.Add(30 * time.Second)
+	if v, _ := m.Do("key", fn); v != 2 {
+		t.Errorf("Do after TTL = %v; want 2", v)
+	}
+
+	// Forget之后重新执行
+	m.Forget("key")
+	if v, _ := m.Do("key", fn); v != 3 {
+		t.Errorf("Do after Forget = %v; want 3", v)
+	}
+}
+
+// 错误结果使用ErrTTL
+func TestGroupCacheErrTTL(t *testing.T) {
+	now := time.Unix(0, 0)
+	m := &GroupCache{TTL: time.Minute, ErrTTL: time.Second}
+	m.now = func() time.Time { return now }
+
+	calls := 0
+	someErr := errors.New("some error")
+	fn := func() (interface{}, error) {
+		calls++
+		return nil, someErr
+	}
+	m.Do("key", fn)
+	if _, err := m.Do("key", fn); err != someErr || calls != 1 {
+		t.Errorf("Do = %v after %d calls; want cached someErr after 1 call", err, calls)
+	}
+	now = now.Add(time.Second)
+	m.Do("key", fn)
+	if calls != 2 {
+		t.Errorf("got %d calls; want 2 after ErrTTL", calls)
+	}
+}
+
+// fn执行期间调用Forget，过时的结果不会被缓存
+func TestGroupCacheForgetInFlight(t *testing.T) {
+	m := &GroupCache{TTL: time.Minute}
+
+	started := make(chan struct{})
+	release := make(chan struct{})
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		m.Do("key", func() (interface{}, error) {
+			close(started)
+			<-release
+			return "stale", nil
+		})
+	}()
+	<-started
+	m.Forget("key")
+	close(release)
+	<-done
+
+	v, _ := m.Do("key", func() (interface{}, error) { return "fresh", nil })
+	if v != "fresh" {
+		t.Errorf("Do after Forget = %v; want fresh", v)
+	}
+	if n := len(m.flights); n != 0 {
+		t.Errorf("got %d in-flight keys after all calls returned; want 0", n)
+	}
+}
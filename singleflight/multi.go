@@ -0,0 +1,147 @@
+/*
+Copyright 2012 Google Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package singleflight
+
+import (
+	"runtime/debug"
+	"time"
+)
+
+// 批量执行请求，keys中已经有请求在执行的key等待已有的请求，其余的key合并为一次fn(missing)
+// fn返回每个key的结果，结果中没有的key或者值为nil的key视为不存在，不会出现在返回值中
+// fn执行期间，其他调用者对missing中任意一个key的Do和DoMulti都会等待这次执行
+// fn返回错误时返回这个错误，等待的key出错时返回第一个错误，fn发生panic时重新panic
+// Group关闭之后返回ErrClosed
+// 设置了KeyFunc时，等价的key只有第一个出现在missing中，返回值包含keys中的每一种写法
+func (g *Group) DoMulti(keys []string, fn func(missing []string) (map[string]interface{}, error)) (map[string]interface{}, error) {
+	norms := make([]string, len(keys))
+	for i, key := range keys {
+		norms[i] = g.normalize(key)
+	}
+
+	g.mu.Lock()
+	if g.closed {
+		g.mu.Unlock()
+		return nil, ErrClosed
+	}
+	if g.m == nil {
+		g.m = make(map[string]*call)
+	}
+	calls := make(map[string]*call)
+	var joined []string
+	var missing, missingNorms []string
+	var started []*call
+	for i, nk := range norms {
+		if calls[nk] != nil {
+			continue
+		}
+		g.stats.Calls++
+		if c, ok := g.m[nk]; ok {
+			g.stats.Dedups++
+			c.dups++
+			c.refs++
+			calls[nk] = c
+			joined = append(joined, nk)
+			continue
+		}
+		g.stats.Executions++
+		c := &call{refs: 1}
+		c.wg.Add(1)
+		g.put(nk, c)
+		calls[nk] = c
+		started = append(started, c)
+		missing = append(missing, keys[i])
+		missingNorms = append(missingNorms, nk)
+	}
+	g.mu.Unlock()
+	for _, nk := range joined {
+		g.duplicate(nk)
+	}
+
+	if len(missing) > 0 {
+		err := g.doMultiCall(started, missing, missingNorms, fn)
+		if pe, ok := err.(*PanicError); ok {
+			panic(pe)
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	var firstErr error
+	for _, nk := range joined {
+		c := calls[nk]
+		c.wg.Wait()
+		if pe, ok := c.err.(*PanicError); ok {
+			panic(pe)
+		}
+		if c.err != nil && firstErr == nil {
+			firstErr = c.err
+		}
+	}
+	if firstErr != nil {
+		return nil, firstErr
+	}
+
+	results := make(map[string]interface{}, len(keys))
+	for i, key := range keys {
+		if v := calls[norms[i]].val; v != nil {
+			results[key] = v
+		}
+	}
+	return results, nil
+}
+
+// 执行批量请求，把missing中每个key的结果分发给calls中对应的请求，并完成清理
+// norms是missing中每个key在哈希表中的key
+// fn发生panic或者调用runtime.Goexit时同样完成清理，等待的调用者不会一直阻塞
+func (g *Group) doMultiCall(calls []*call, missing, norms []string, fn func(missing []string) (map[string]interface{}, error)) (err error) {
+	var res map[string]interface{}
+	normalReturn := false
+	defer func() {
+		for i, c := range calls {
+			c.val, c.err = res[missing[i]], err
+			g.finish(c, norms[i])
+		}
+	}()
+	defer func() {
+		if normalReturn {
+			return
+		}
+		if r := recover(); r != nil {
+			err = &PanicError{Value: r, Stack: debug.Stack()}
+		} else {
+			err = errGoexit
+		}
+	}()
+
+	if sem := g.semaphore(); sem != nil {
+		sem <- struct{}{}
+		defer func() { <-sem }()
+	}
+	if g.OnComplete != nil {
+		defer func(start time.Time) {
+			elapsed := time.Since(start)
+			for _, c := range calls {
+				c.elapsed = elapsed
+			}
+		}(time.Now())
+	}
+	res, err = fn(missing)
+	normalReturn = true
+	return err
+}
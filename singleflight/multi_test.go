@@ -0,0 +1,74 @@
+/*
+Copyright 2012 Google Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package singleflight
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+	"testing"
+	"time"
+)
+
+// 测试两个重叠的批量请求共享相同key的执行
+func TestDoMulti(t *testing.T) {
+	var g Group
+	var mu sync.Mutex
+	var fetched [][]string
+	release := make(chan struct{})
+	fn := func(missing []string) (map[string]interface{}, error) {
+		mu.Lock()
+		sorted := append([]string(nil), missing...)
+		sort.Strings(sorted)
+		fetched = append(fetched, sorted)
+		mu.Unlock()
+		<-release
+		res := make(map[string]interface{})
+		for _, key := range missing {
+			if key != "missing" {
+				res[key] = "v" + key
+			}
+		}
+		return res, nil
+	}
+
+	first := make(chan map[string]interface{})
+	go func() {
+		res, _ := g.DoMulti([]string{"a", "b", "c"}, fn)
+		first <- res
+	}()
+	time.Sleep(50 * time.Millisecond)
+
+	second := make(chan map[string]interface{})
+	go func() {
+		res, _ := g.DoMulti([]string{"b", "c", "d", "missing"}, fn)
+		second <- res
+	}()
+	time.Sleep(50 * time.Millisecond)
+	close(release)
+
+	if got, want := fmt.Sprint(<-first), "map[a:va b:vb c:vc]"; got != want {
+		t.Errorf("first DoMulti = %s; want %s", got, want)
+	}
+	if got, want := fmt.Sprint(<-second), "map[b:vb c:vc d:vd]"; got != want {
+		t.Errorf("second DoMulti = %s; want %s", got, want)
+	}
+	// b和c只获取了一次
+	if got, want := fmt.Sprint(fetched), "[[a b c] [d missing]]"; got != want {
+		t.Errorf("fetched %s; want %s", got, want)
+	}
+}
@@ -0,0 +1,88 @@
+/*
+Copyright 2012 Google Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package singleflight
+
+import "context"
+
+// 默认的分片数量
+const defaultShards = 16
+
+// ShardedGroup将key按照哈希值分散到多个Group，每个分片有独立的锁
+// 不同分片的key可以并发执行Do，减少锁竞争
+type ShardedGroup struct {
+	shards []Group
+	mask   uint32
+}
+
+// ShardedGroup结构的构造函数，shards会向上取整到2的幂，小于等于0时使用默认值16
+func NewShardedGroup(shards int) *ShardedGroup {
+	if shards <= 0 {
+		shards = defaultShards
+	}
+	n := 1
+	for n < shards {
+		n <<= 1
+	}
+	return &ShardedGroup{
+		shards: make([]Group, n),
+		mask:   uint32(n - 1),
+	}
+}
+
+// 根据key的FNV-1a哈希值选择分片
+func (sg *ShardedGroup) shard(key string) *Group {
+	h := uint32(2166136261)
+	for i := 0; i < len(key); i++ {
+		h ^= uint32(key[i])
+		h *= 16777619
+	}
+	return &sg.shards[h&sg.mask]
+}
+
+// 和Group.Do相同
+func (sg *ShardedGroup) Do(key string, fn func() (interface{}, error)) (interface{}, error) {
+	return sg.shard(key).Do(key, fn)
+}
+
+// 和Group.DoShared相同
+func (sg *ShardedGroup) DoShared(key string, fn func() (interface{}, error)) (v interface{}, err error, shared bool) {
+	return sg.shard(key).DoShared(key, fn)
+}
+
+// 和Group.DoChan相同
+func (sg *ShardedGroup) DoChan(key string, fn func() (interface{}, error)) <-chan Result {
+	return sg.shard(key).DoChan(key, fn)
+}
+
+// 和Group.DoContext相同
+func (sg *ShardedGroup) DoContext(ctx context.Context, key string, fn func(context.Context) (interface{}, error)) (interface{}, error) {
+	return sg.shard(key).DoContext(ctx, key, fn)
+}
+
+// 和Group.Forget相同
+func (sg *ShardedGroup) Forget(key string) {
+	sg.shard(key).Forget(key)
+}
+
+// 获取所有分片中正在执行的key的数量
+func (sg *ShardedGroup) InFlight() int {
+	n := 0
+	for i := range sg.shards {
+		n += sg.shards[i].InFlight()
+	}
+	return n
+}
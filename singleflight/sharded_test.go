@@ -0,0 +1,106 @@
+/*
+Copyright 2012 Google Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package singleflight
+
+import (
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestShardedGroupDo(t *testing.T) {
+	sg := NewShardedGroup(0)
+	if got := len(sg.shards); got != defaultShards {
+		t.Fatalf("got %d shards; want %d", got, defaultShards)
+	}
+	if got := len(NewShardedGroup(5).shards); got != 8 {
+		t.Fatalf("got %d shards; want 8", got)
+	}
+
+	release := make(chan struct{})
+	var calls int32
+	fn := func() (interface{}, error) {
+		atomic.AddInt32(&calls, 1)
+		<-release
+		return "bar", nil
+	}
+
+	const keys, n = 8, 4
+	var wg sync.WaitGroup
+	for i := 0; i < keys; i++ {
+		for j := 0; j < n; j++ {
+			wg.Add(1)
+			go func(key string) {
+				defer wg.Done()
+				if v, err := sg.Do(key, fn); v != "bar" || err != nil {
+					t.Errorf("Do = %v, %v; want bar, nil", v, err)
+				}
+			}(fmt.Sprint("key", i))
+		}
+	}
+	// 等待所有调用者都进入Do
+	for {
+		var total int64
+		for i := range sg.shards {
+			total += sg.shards[i].Stats().Calls
+		}
+		if total == keys*n {
+			break
+		}
+		time.Sleep(time.Millisecond)
+	}
+	if got := sg.InFlight(); got != keys {
+		t.Errorf("InFlight = %d; want %d", got, keys)
+	}
+	close(release)
+	wg.Wait()
+
+	if got := atomic.LoadInt32(&calls); got != keys {
+		t.Errorf("fn called %d times; want %d", got, keys)
+	}
+	if got := sg.InFlight(); got != 0 {
+		t.Errorf("InFlight = %d; want 0", got)
+	}
+}
+
+// 不同的key并发执行Do
+func benchmarkDistinctKeys(b *testing.B, do func(key string, fn func() (interface{}, error)) (interface{}, error)) {
+	keys := make([]string, 1024)
+	for i := range keys {
+		keys[i] = fmt.Sprint("key", i)
+	}
+	fn := func() (interface{}, error) { return nil, nil }
+	var next uint32
+	b.RunParallel(func(pb *testing.PB) {
+		i := int(atomic.AddUint32(&next, 1)) * 97
+		for pb.Next() {
+			do(keys[i%len(keys)], fn)
+			i++
+		}
+	})
+}
+
+func BenchmarkGroupDistinctKeys(b *testing.B) {
+	var g Group
+	benchmarkDistinctKeys(b, g.Do)
+}
+
+func BenchmarkShardedGroupDistinctKeys(b *testing.B) {
+	benchmarkDistinctKeys(b, NewShardedGroup(0).Do)
+}
@@ -17,26 +17,153 @@ limitations under the License.
 // 控制重复的请求只执行1次
 package singleflight
 
-import "sync"
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"runtime"
+	"runtime/debug"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// DoTimeout等待超时时返回的错误
+var ErrTimeout = errors.New("singleflight: timed out waiting for fn")
+
+// Group关闭之后发起请求返回的错误
+var ErrClosed = errors.New("singleflight: group is closed")
+
+// 开启DetectReentrancy时，fn在执行协程中对同一个key调用Do返回的错误
+// 否则Do会一直等待自己完成，出现死锁
+var ErrReentrant = errors.New("singleflight: fn called Do with its own in-flight key")
+
+// fn调用了runtime.Goexit时，等待的调用者得到的错误
+var errGoexit = errors.New("singleflight: fn called runtime.Goexit")
+
+// PanicError是fn发生panic时的错误，Do和DoShared会在所有调用者中重新panic
+// DoChan和DoContext的调用者通过Result.Err得到PanicError
+type PanicError struct {
+	Value interface{} // recover返回的值
+	Stack []byte      // 发生panic时的调用栈
+}
+
+func (p *PanicError) Error() string {
+	return fmt.Sprintf("singleflight: fn panicked: %v\n\n%s", p.Value, p.Stack)
+}
 
 // 执行中或者执行完成的结果
 type call struct {
 	wg  sync.WaitGroup
 	val interface{}
 	err error
+
+	// DoChan的调用者等待结果的通道
+	// chanLeader为true时，第一个通道属于执行请求的调用者
+	chans      []chan<- Result
+	chanLeader bool
+	// IndependentErrors重新发起请求时，等待的通道转移到的请求
+	next *call
+
+	// 执行的请求，ctxFn不为空时使用ctxFn
+	// 传给ctxFn的context在所有调用者都放弃等待时取消
+	fn     func() (interface{}, error)
+	ctxFn  func(context.Context) (interface{}, error)
+	ctx    context.Context
+	cancel context.CancelFunc
+
+	// 还在等待结果的调用者数量，包括执行请求的调用者
+	refs int
+
+	// 执行fn的协程id，只在开启DetectReentrancy时记录
+	goid atomic.Uint64
+
+	// fn的执行时间，只在设置了OnComplete时记录
+	elapsed time.Duration
+
+	// 等待这个请求结果的其他调用者的数量
+	dups int
+}
+
+// DoChan返回的结果
+type Result struct {
+	Val    interface{}
+	Err    error
+	Shared bool // 是否等待其他调用者执行的请求，而不是自己执行
 }
 
 // Group代表重复请求的一组操作
 type Group struct {
-	mu sync.Mutex
-	m  map[string]*call
+	// 同时执行fn的最大数量，0 代表没有限制，需要在使用Group之前设置
+	// 超出限制的请求按照先后顺序排队，等待其他调用者执行结果的请求不占用名额
+	MaxConcurrent int
+
+	// 调用者等待其他调用者执行的请求时触发的回调函数，执行请求的调用者不会触发
+	// 在释放锁之后调用，可以用来统计每个key的重复次数
+	OnDuplicate func(key string)
+
+	// 为true时不共享错误结果，fn返回错误时只有执行请求的调用者得到这个错误
+	// 执行期间等待的调用者会重新发起请求，同时重新发起的请求仍然会合并执行
+	// 代价是放大重试：后端持续出错时，fn最多会执行等待的调用者数量加1次
+	// fn发生panic时仍然在所有调用者中重新panic，DoMulti不受影响
+	IndependentErrors bool
+
+	// 为true时检测fn在执行协程中对同一个key调用Do，这时Do返回ErrReentrant而不是死锁
+	// 只能检测同一个协程中的重入，fn在其他协程中调用Do并等待仍然会死锁
+	// 检测需要获取协程id，每次调用都有额外的开销，建议只在调试时开启
+	DetectReentrancy bool
+
+	// 不为空时用KeyFunc(key)合并请求，例如忽略大小写或者末尾的斜杠，需要在使用Group之前设置
+	// 等价的key合并为一次执行，执行的是第一个调用者的fn，所以fn实际看到的是第一个调用者的key
+	// OnDuplicate、Forget和ForgetUnshared同样使用转换之后的key
+	KeyFunc func(key string) string
+
+	// 每次执行fn完成之后触发的回调函数，d是fn的执行时间，不包括等待MaxConcurrent的时间
+	// 只有执行请求的调用者触发，等待的调用者不会触发，在释放锁之后调用
+	OnComplete func(key string, d time.Duration, err error)
+
+	mu    sync.Mutex
+	m     map[string]*call
+	peak  int // 上次重建之后m的最大元素数量
+	stats Stats
+	sem   chan struct{} // 限制并发数量的信号量
+
+	closed  bool           // 是否已经关闭，关闭之后不再接受新的请求
+	leaders sync.WaitGroup // 正在执行的请求
+}
+
+// Group的统计数据
+type Stats struct {
+	Calls      int64 // 所有调用的次数
+	Executions int64 // 实际执行fn的次数
+	Dedups     int64 // 等待其他调用者执行结果的次数
+	InFlight   int   // 正在执行的key的数量
 }
 
 // 保证对同一个key的请求不会出现并发重复操作
 // 如果存在重复请求，等待上一个操作完成返回相同响应
 func (g *Group) Do(key string, fn func() (interface{}, error)) (interface{}, error) {
+	v, err, _ := g.DoShared(key, fn)
+	return v, err
+}
+
+// 和Do相同，shared表示是否等待了其他调用者执行的请求
+// 只有执行请求的调用者shared为false
+func (g *Group) DoShared(key string, fn func() (interface{}, error)) (v interface{}, err error, shared bool) {
+	key = g.normalize(key)
+	var id uint64
+	if g.DetectReentrancy {
+		id = goid()
+	}
+
 	// 加锁操作
 	g.mu.Lock()
+	if g.closed {
+		g.mu.Unlock()
+		return nil, ErrClosed, false
+	}
 
 	// 延迟初始化
 	if g.m == nil {
@@ -44,25 +171,429 @@ func (g *Group) Do(key string, fn func() (interface{}, error)) (interface{}, err
 	}
 
 	// 如果存在重复请求，阻塞，等待WaitGroup Done，返回响应和错误
-	if c, ok := g.m[key]; ok {
+	g.stats.Calls++
+	for {
+		c, ok := g.m[key]
+		if !ok {
+			break
+		}
+		if id != 0 && c.goid.Load() == id {
+			g.mu.Unlock()
+			return nil, fmt.Errorf("%w: key %q", ErrReentrant, key), false
+		}
+		g.stats.Dedups++
+		c.dups++
+		c.refs++
 		g.mu.Unlock()
+		g.duplicate(key)
 		c.wg.Wait()
-		return c.val, c.err
+		if pe, ok := c.err.(*PanicError); ok {
+			panic(pe)
+		}
+		if c.err == nil || !g.IndependentErrors {
+			return c.val, c.err, true
+		}
+		// 不共享错误结果，重新发起请求，Group已经关闭时返回这个错误
+		g.mu.Lock()
+		if g.closed {
+			g.mu.Unlock()
+			return c.val, c.err, true
+		}
 	}
 
 	// 如果不存在重复请求，创建Call结构和WaitGroup
-	c := new(call)
+	g.stats.Executions++
+	c := &call{fn: fn, refs: 1}
 	c.wg.Add(1)
-	g.m[key] = c
+	g.put(key, c)
 	g.mu.Unlock()
 
-	// 执行请求操作，完成之后删除对应的哈希表记录
-	c.val, c.err = fn()
+	g.doCall(c, key)
+	if pe, ok := c.err.(*PanicError); ok {
+		panic(pe)
+	}
+	return c.val, c.err, false
+}
+
+// 和Do相同，但是不阻塞，返回接收结果的通道
+// 通道的缓冲区大小是1，调用者不读取结果也不会阻塞执行请求的协程
+func (g *Group) DoChan(key string, fn func() (interface{}, error)) <-chan Result {
+	key = g.normalize(key)
+	ch := make(chan Result, 1)
+	g.mu.Lock()
+	if g.closed {
+		g.mu.Unlock()
+		ch <- Result{Err: ErrClosed}
+		return ch
+	}
+	if g.m == nil {
+		g.m = make(map[string]*call)
+	}
+	g.stats.Calls++
+	c, leader := g.joinChan(ch, key, fn, nil)
+	g.mu.Unlock()
+
+	if leader {
+		go g.doCall(c, key)
+	} else {
+		g.duplicate(key)
+	}
+	return ch
+}
+
+// 把通道加入key对应的请求，不存在请求时创建新的请求，leader为true时由调用者执行
+// fn和ctxFn只需要设置一个，调用时需要持有锁
+func (g *Group) joinChan(ch chan<- Result, key string, fn func() (interface{}, error), ctxFn func(context.Context) (interface{}, error)) (c *call, leader bool) {
+	if c, ok := g.m[key]; ok {
+		g.stats.Dedups++
+		c.dups++
+		c.refs++
+		c.chans = append(c.chans, ch)
+		return c, false
+	}
+	g.stats.Executions++
+	c = &call{chans: []chan<- Result{ch}, chanLeader: true, fn: fn, ctxFn: ctxFn, refs: 1}
+	c.wg.Add(1)
+	g.put(key, c)
+	return c, true
+}
+
+// 和Do相同，但是ctx结束时立即返回ctx.Err()，不再等待请求完成
+// 请求在单独的协程中执行，部分调用者取消不会影响请求，其他等待的调用者仍然得到结果
+// 传给fn的context保留执行请求的调用者ctx的值，但是不会随着它取消
+// 所有调用者都取消之后，传给fn的context被取消，之后对key的请求会重新执行
+func (g *Group) DoContext(ctx context.Context, key string, fn func(context.Context) (interface{}, error)) (interface{}, error) {
+	key = g.normalize(key)
+	ch := make(chan Result, 1)
+	g.mu.Lock()
+	if g.closed {
+		g.mu.Unlock()
+		return nil, ErrClosed
+	}
+	if g.m == nil {
+		g.m = make(map[string]*call)
+	}
+	g.stats.Calls++
+	c, leader := g.joinChan(ch, key, nil, fn)
+	if leader {
+		// 传给fn的context保留ctx的值，但是不会随着ctx取消
+		c.ctx, c.cancel = context.WithCancel(context.WithoutCancel(ctx))
+	}
+	g.mu.Unlock()
+
+	if leader {
+		go g.doCall(c, key)
+	} else {
+		g.duplicate(key)
+	}
+	select {
+	case <-ctx.Done():
+		g.release(c, ch, key)
+		return nil, ctx.Err()
+	case res := <-ch:
+		return res.Val, res.Err
+	}
+}
+
+// 调用者放弃等待请求，所有调用者都放弃时取消传给fn的context，并忘记请求
+// IndependentErrors重新发起请求时ch转移到了新的请求，沿着next找到ch所在的请求
+// 请求已经完成、结果正在发送时不需要处理
+func (g *Group) release(c *call, ch chan<- Result, key string) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	i := -1
+	for ; c != nil; c = c.next {
+		if i = chanIndex(c.chans, ch); i >= 0 {
+			break
+		}
+	}
+	if c == nil {
+		return
+	}
+	c.chans = append(c.chans[:i], c.chans[i+1:]...)
+	if i == 0 && c.chanLeader {
+		c.chanLeader = false
+	}
+	c.refs--
+	if c.refs > 0 {
+		return
+	}
+	if g.m[key] == c {
+		g.remove(key)
+	}
+	if c.cancel != nil {
+		c.cancel()
+	}
+}
+
+// 查找ch在chans中的下标，不存在时返回-1
+func chanIndex(chans []chan<- Result, ch chan<- Result) int {
+	for i, c := range chans {
+		if c == ch {
+			return i
+		}
+	}
+	return -1
+}
+
+// 和Do相同，但是最多等待timeout，超时时timeoutErr为ErrTimeout
+// 请求在单独的协程中执行，超时不会影响请求，其他等待的调用者仍然得到结果
+// fn发生panic时err是PanicError，不会重新panic
+func (g *Group) DoTimeout(key string, timeout time.Duration, fn func() (interface{}, error)) (v interface{}, err error, timeoutErr error) {
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+	select {
+	case res := <-g.DoChan(key, fn):
+		return res.Val, res.Err, nil
+	case <-timer.C:
+		return nil, nil, ErrTimeout
+	}
+}
+
+// 和DoTimeout相同，但是超时或者fn返回错误时返回fallback()的结果，fn发生panic时重新panic
+// 超时不会取消请求，其他等待的调用者仍然得到请求的结果
+func (g *Group) DoWithFallback(key string, timeout time.Duration, fn func() (interface{}, error), fallback func() interface{}) interface{} {
+	v, err, timeoutErr := g.DoTimeout(key, timeout, fn)
+	if pe, ok := err.(*PanicError); ok {
+		panic(pe)
+	}
+	if err != nil || timeoutErr != nil {
+		return fallback()
+	}
+	return v
+}
+
+// 执行请求操作，完成之后删除对应的哈希表记录，并把结果发送给DoChan的调用者
+// fn发生panic或者调用runtime.Goexit时同样完成清理，等待的调用者不会一直阻塞
+func (g *Group) doCall(c *call, key string) {
+	normalReturn := false
+	defer g.finish(c, key)
+	defer func() {
+		if normalReturn {
+			return
+		}
+		if r := recover(); r != nil {
+			c.err = &PanicError{Value: r, Stack: debug.Stack()}
+		} else {
+			c.err = errGoexit
+		}
+	}()
+
+	if sem := g.semaphore(); sem != nil {
+		sem <- struct{}{}
+		defer func() { <-sem }()
+	}
+	if g.DetectReentrancy {
+		c.goid.Store(goid())
+	}
+	if g.OnComplete != nil {
+		defer func(start time.Time) { c.elapsed = time.Since(start) }(time.Now())
+	}
+	if c.ctxFn != nil {
+		c.val, c.err = c.ctxFn(c.ctx)
+	} else {
+		c.val, c.err = c.fn()
+	}
+	normalReturn = true
+}
+
+// 从调用栈的第一行"goroutine N [running]:"解析当前协程的id
+func goid() uint64 {
+	var buf [64]byte
+	b := buf[:runtime.Stack(buf[:], false)]
+	b = bytes.TrimPrefix(b, []byte("goroutine "))
+	if i := bytes.IndexByte(b, ' '); i >= 0 {
+		b = b[:i]
+	}
+	id, _ := strconv.ParseUint(string(b), 10, 64)
+	return id
+}
+
+// 获取key在哈希表中的key，没有设置KeyFunc时就是key本身
+func (g *Group) normalize(key string) string {
+	if g.KeyFunc != nil {
+		return g.KeyFunc(key)
+	}
+	return key
+}
+
+// 触发OnDuplicate回调函数，调用时不能持有锁
+func (g *Group) duplicate(key string) {
+	if g.OnDuplicate != nil {
+		g.OnDuplicate(key)
+	}
+}
+
+// 获取限制并发数量的信号量，没有限制时返回nil
+func (g *Group) semaphore() chan struct{} {
+	if g.MaxConcurrent <= 0 {
+		return nil
+	}
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if g.sem == nil {
+		g.sem = make(chan struct{}, g.MaxConcurrent)
+	}
+	return g.sem
+}
+
+// 请求完成之后唤醒等待的调用者，删除哈希表记录，并发送结果
+func (g *Group) finish(c *call, key string) {
+	if c.cancel != nil {
+		c.cancel()
+	}
+	// 执行期间可能调用了Forget，只删除自己的记录
+	// 先删除记录再唤醒等待的调用者，重新发起请求的调用者不会再次找到这个请求
+	g.mu.Lock()
+	if g.m[key] == c {
+		g.remove(key)
+	}
+	// 之后放弃等待的调用者不需要再处理这些通道
+	chans := c.chans
+	c.chans = nil
+	var retry *call
+	var dups int
+	if _, ok := c.err.(*PanicError); c.err != nil && !ok && g.IndependentErrors && (c.fn != nil || c.ctxFn != nil) && c.refs > 0 {
+		// 不共享错误结果，等待的DoChan调用者重新发起请求
+		waiters := chans
+		chans = nil
+		if c.chanLeader {
+			chans, waiters = waiters[:1], waiters[1:]
+		}
+		for _, ch := range waiters {
+			nc, leader := g.joinChan(ch, key, c.fn, c.ctxFn)
+			c.next = nc
+			if leader {
+				if nc.ctxFn != nil {
+					nc.ctx, nc.cancel = context.WithCancel(context.WithoutCancel(c.ctx))
+				}
+				retry = nc
+			} else {
+				dups++
+			}
+		}
+	}
+	g.mu.Unlock()
 	c.wg.Done()
 
+	if retry != nil {
+		go g.doCall(retry, key)
+	}
+	for i := 0; i < dups; i++ {
+		g.duplicate(key)
+	}
+	if g.OnComplete != nil {
+		g.OnComplete(key, c.elapsed, c.err)
+	}
+	for i, ch := range chans {
+		ch <- Result{Val: c.val, Err: c.err, Shared: i > 0 || !c.chanLeader}
+	}
+	g.leaders.Done()
+}
+
+// 获取统计数据的副本
+func (g *Group) Stats() Stats {
 	g.mu.Lock()
+	defer g.mu.Unlock()
+	stats := g.stats
+	stats.InFlight = len(g.m)
+	return stats
+}
+
+// 记录key对应的请求，调用时需要持有锁
+func (g *Group) put(key string, c *call) {
+	g.leaders.Add(1)
+	g.m[key] = c
+	if len(g.m) > g.peak {
+		g.peak = len(g.m)
+	}
+}
+
+// 重建哈希表的阈值，元素数量曾经超过shrinkMinPeak，
+// 并且降到最大数量的1/shrinkRatio以下时重建
+const (
+	shrinkMinPeak = 1024
+	shrinkRatio   = 8
+)
+
+// 删除key对应的请求，调用时需要持有锁
+// 哈希表删除元素之后不会释放内存，元素数量远小于最大数量时重建哈希表
+func (g *Group) remove(key string) {
 	delete(g.m, key)
+	if g.peak < shrinkMinPeak || len(g.m) > g.peak/shrinkRatio {
+		return
+	}
+	m := make(map[string]*call, len(g.m))
+	for k, c := range g.m {
+		m[k] = c
+	}
+	g.m = m
+	g.peak = len(m)
+}
+
+// 获取哈希表中的key的数量，和InFlight相同
+func (g *Group) Len() int {
+	return g.InFlight()
+}
+
+// 获取正在执行的key的数量，可以作为负载的信号
+func (g *Group) InFlight() int {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	return len(g.m)
+}
+
+// 将统计数据清零
+func (g *Group) ResetStats() {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.stats = Stats{}
+}
+
+// 只有还没有其他调用者等待key对应的请求时才忘记请求，返回key是否已经被忘记
+// key没有对应的请求时返回true
+func (g *Group) ForgetUnshared(key string) bool {
+	key = g.normalize(key)
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	c, ok := g.m[key]
+	if !ok {
+		return true
+	}
+	if c.dups == 0 {
+		g.remove(key)
+		return true
+	}
+	return false
+}
+
+// 关闭Group，之后的请求直接返回ErrClosed，不会执行fn
+// 正在执行的请求仍然会完成，已经在等待的调用者仍然得到结果，可以调用Wait等待它们完成
+func (g *Group) Close() {
+	g.mu.Lock()
+	g.closed = true
 	g.mu.Unlock()
+}
+
+// 等待所有正在执行的请求完成，需要在Close之后调用，否则可能和新的请求并发修改计数
+func (g *Group) Wait() {
+	g.leaders.Wait()
+}
 
-	return c.val, c.err
+// 忘记所有正在执行的请求，之后的请求都会重新执行，主要用于测试隔离和动态修改配置
+// 正在执行的请求仍然会完成，已经在等待的调用者仍然得到之前请求的响应
+func (g *Group) Reset() {
+	g.mu.Lock()
+	g.m = nil
+	g.peak = 0
+	g.mu.Unlock()
+}
+
+// 忘记key对应的请求，之后对key的请求会重新执行，即使之前的请求还在执行
+// 已经在等待的请求仍然返回之前请求的响应
+func (g *Group) Forget(key string) {
+	key = g.normalize(key)
+	g.mu.Lock()
+	g.remove(key)
+	g.mu.Unlock()
 }
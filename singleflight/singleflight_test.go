@@ -17,8 +17,11 @@ limitations under the License.
 package singleflight
 
 import (
+	"context"
 	"errors"
 	"fmt"
+	"runtime"
+	"strings"
 	"sync"
 	"sync/atomic"
 	"testing"
@@ -92,3 +95,955 @@ func TestDoDupSuppress(t *testing.T) {
 		t.Errorf("number of calls = %d; want 1", got)
 	}
 }
+
+// 测试执行期间调用Forget之后，新的请求会重新执行
+func TestForget(t *testing.T) {
+	var g Group
+	var calls int32
+	first := make(chan struct{})
+	release := make(chan struct{})
+
+	done := make(chan interface{})
+	go func() {
+		v, _ := g.Do("key", func() (interface{}, error) {
+			atomic.AddInt32(&calls, 1)
+			close(first)
+			<-release
+			return 1, nil
+		})
+		done <- v
+	}()
+	<-first
+
+	g.Forget("key")
+	v, _ := g.Do("key", func() (interface{}, error) {
+		atomic.AddInt32(&calls, 1)
+		return 2, nil
+	})
+	if v != 2 {
+		t.Errorf("Do after Forget = %v; want 2", v)
+	}
+
+	close(release)
+	if v := <-done; v != 1 {
+		t.Errorf("first Do = %v; want 1", v)
+	}
+	if got := atomic.LoadInt32(&calls); got != 2 {
+		t.Errorf("number of calls = %d; want 2", got)
+	}
+}
+
+// 测试多个DoChan的调用者只执行1次请求
+func TestDoChan(t *testing.T) {
+	var g Group
+	var calls int32
+	release := make(chan struct{})
+	fn := func() (interface{}, error) {
+		atomic.AddInt32(&calls, 1)
+		<-release
+		return "bar", nil
+	}
+
+	const n = 10
+	chans := make([]<-chan Result, n)
+	for i := range chans {
+		chans[i] = g.DoChan("key", fn)
+	}
+	close(release)
+
+	shared := 0
+	for _, ch := range chans {
+		res := <-ch
+		if res.Err != nil || res.Val != "bar" {
+			t.Errorf("DoChan = %v, %v; want bar, nil", res.Val, res.Err)
+		}
+		if res.Shared {
+			shared++
+		}
+	}
+	if got := atomic.LoadInt32(&calls); got != 1 {
+		t.Errorf("number of calls = %d; want 1", got)
+	}
+	if shared != n-1 {
+		t.Errorf("got %d shared results; want %d", shared, n-1)
+	}
+}
+
+// 测试等待的调用者取消之后，执行请求的调用者仍然得到结果
+func TestDoContextCancel(t *testing.T) {
+	var g Group
+	started := make(chan struct{})
+	release := make(chan struct{})
+	fn := func(ctx context.Context) (interface{}, error) {
+		close(started)
+		<-release
+		return "bar", ctx.Err()
+	}
+
+	leader := make(chan error)
+	go func() {
+		v, err := g.DoContext(context.Background(), "key", fn)
+		if v != "bar" {
+			err = fmt.Errorf("got %v; want bar", v)
+		}
+		leader <- err
+	}()
+	<-started
+
+	ctx, cancel := context.WithCancel(context.Background())
+	waiter := make(chan error)
+	go func() {
+		_, err := g.DoContext(ctx, "key", fn)
+		waiter <- err
+	}()
+	cancel()
+	if err := <-waiter; err != context.Canceled {
+		t.Errorf("cancelled DoContext error = %v; want context.Canceled", err)
+	}
+
+	close(release)
+	if err := <-leader; err != nil {
+		t.Errorf("leader DoContext error = %v", err)
+	}
+}
+
+// 测试并发请求中只有1个调用者执行请求
+func TestDoShared(t *testing.T) {
+	var g Group
+	release := make(chan struct{})
+	fn := func() (interface{}, error) {
+		<-release
+		return "bar", nil
+	}
+
+	const n = 10
+	var notShared int32
+	var wg sync.WaitGroup
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, _, shared := g.DoShared("key", fn)
+			if !shared {
+				atomic.AddInt32(&notShared, 1)
+			}
+		}()
+	}
+	// 等所有协程启动之后再让请求完成
+	time.Sleep(100 * time.Millisecond)
+	close(release)
+	wg.Wait()
+
+	if got := atomic.LoadInt32(&notShared); got != 1 {
+		t.Errorf("got %d callers with shared=false; want 1", got)
+	}
+}
+
+// 测试fn发生panic时，执行请求和等待的调用者都不会阻塞，并且都重新panic
+func TestDoPanic(t *testing.T) {
+	var g Group
+	release := make(chan struct{})
+	fn := func() (interface{}, error) {
+		<-release
+		panic("boom")
+	}
+
+	const n = 5
+	panics := make(chan interface{}, n)
+	for i := 0; i < n; i++ {
+		go func() {
+			defer func() {
+				panics <- recover()
+			}()
+			g.Do("key", fn)
+		}()
+	}
+	time.Sleep(100 * time.Millisecond)
+	close(release)
+
+	for i := 0; i < n; i++ {
+		select {
+		case r := <-panics:
+			if pe, ok := r.(*PanicError); !ok || pe.Value != "boom" {
+				t.Errorf("got panic %v; want *PanicError with boom", r)
+			}
+		case <-time.After(time.Second):
+			t.Fatal("Do did not return after fn panicked")
+		}
+	}
+
+	// DoChan的调用者通过错误得到PanicError
+	res := <-g.DoChan("key", func() (interface{}, error) { panic("boom") })
+	if _, ok := res.Err.(*PanicError); !ok {
+		t.Errorf("DoChan error = %v; want *PanicError", res.Err)
+	}
+}
+
+// 测试fn调用runtime.Goexit时，等待的调用者得到错误
+func TestDoGoexit(t *testing.T) {
+	var g Group
+	res := <-g.DoChan("key", func() (interface{}, error) {
+		runtime.Goexit()
+		return nil, nil
+	})
+	if res.Err != errGoexit {
+		t.Errorf("DoChan error = %v; want errGoexit", res.Err)
+	}
+}
+
+func TestDoTimeout(t *testing.T) {
+	var g Group
+	v, err, timeoutErr := g.DoTimeout("key", time.Second, func() (interface{}, error) {
+		return "bar", nil
+	})
+	if v != "bar" || err != nil || timeoutErr != nil {
+		t.Errorf("DoTimeout = %v, %v, %v; want bar, nil, nil", v, err, timeoutErr)
+	}
+
+	// 超时之后请求继续执行，其他调用者仍然得到结果
+	release := make(chan struct{})
+	slow := func() (interface{}, error) {
+		<-release
+		return "slow", nil
+	}
+	ch := g.DoChan("slow", slow)
+	if _, _, timeoutErr := g.DoTimeout("slow", 10*time.Millisecond, slow); timeoutErr != ErrTimeout {
+		t.Errorf("DoTimeout timeout error = %v; want ErrTimeout", timeoutErr)
+	}
+	close(release)
+	if res := <-ch; res.Val != "slow" {
+		t.Errorf("DoChan = %v; want slow", res.Val)
+	}
+}
+
+func TestStats(t *testing.T) {
+	var g Group
+	release := make(chan struct{})
+	fn := func() (interface{}, error) {
+		<-release
+		return "bar", nil
+	}
+
+	const n = 10
+	chans := make([]<-chan Result, n)
+	for i := range chans {
+		chans[i] = g.DoChan("key", fn)
+	}
+	if got := g.Stats(); got != (Stats{Calls: n, Executions: 1, Dedups: n - 1, InFlight: 1}) {
+		t.Errorf("Stats = %+v during the call", got)
+	}
+	close(release)
+	for _, ch := range chans {
+		<-ch
+	}
+	if got := g.Stats().InFlight; got != 0 {
+		t.Errorf("got %d in-flight keys after completion; want 0", got)
+	}
+
+	g.ResetStats()
+	if got := g.Stats(); got != (Stats{}) {
+		t.Errorf("Stats = %+v after ResetStats; want zero", got)
+	}
+}
+
+// 测试同时执行fn的数量不超过MaxConcurrent
+func TestMaxConcurrent(t *testing.T) {
+	g := Group{MaxConcurrent: 2}
+	var active, maxActive int32
+	fn := func() (interface{}, error) {
+		n := atomic.AddInt32(&active, 1)
+		for {
+			m := atomic.LoadInt32(&maxActive)
+			if n <= m || atomic.CompareAndSwapInt32(&maxActive, m, n) {
+				break
+			}
+		}
+		time.Sleep(10 * time.Millisecond)
+		atomic.AddInt32(&active, -1)
+		return nil, nil
+	}
+
+	chans := make([]<-chan Result, 0, 10)
+	for i := 0; i < 10; i++ {
+		chans = append(chans, g.DoChan(fmt.Sprint("key", i), fn))
+	}
+	for _, ch := range chans {
+		<-ch
+	}
+	if got := atomic.LoadInt32(&maxActive); got != 2 {
+		t.Errorf("got at most %d concurrent calls; want 2", got)
+	}
+}
+
+func TestForgetUnshared(t *testing.T) {
+	var g Group
+	release := make(chan struct{})
+	fn := func() (interface{}, error) {
+		<-release
+		return "bar", nil
+	}
+	defer close(release)
+
+	// 没有其他调用者等待时可以忘记
+	g.DoChan("unshared", fn)
+	if !g.ForgetUnshared("unshared") {
+		t.Error("ForgetUnshared = false for an unshared call; want true")
+	}
+
+	// 已经有其他调用者等待时不能忘记
+	g.DoChan("shared", fn)
+	g.DoChan("shared", fn)
+	if g.ForgetUnshared("shared") {
+		t.Error("ForgetUnshared = true for a shared call; want false")
+	}
+
+	if !g.ForgetUnshared("missing") {
+		t.Error("ForgetUnshared = false for a missing key; want true")
+	}
+	if got := g.Stats().InFlight; got != 1 {
+		t.Errorf("got %d in-flight keys; want 1", got)
+	}
+}
+
+// 每个等待的调用者触发一次OnDuplicate，执行请求的调用者不触发
+func TestOnDuplicate(t *testing.T) {
+	var dups int32
+	g := Group{OnDuplicate: func(key string) {
+		if key != "key" {
+			t.Errorf("OnDuplicate key = %q; want key", key)
+		}
+		atomic.AddInt32(&dups, 1)
+	}}
+	release := make(chan struct{})
+	var calls int32
+	fn := func() (interface{}, error) {
+		atomic.AddInt32(&calls, 1)
+		<-release
+		return "bar", nil
+	}
+
+	const n = 10
+	var wg sync.WaitGroup
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			g.Do("key", fn)
+		}()
+	}
+	for g.Stats().Calls < n {
+		time.Sleep(time.Millisecond)
+	}
+	close(release)
+	wg.Wait()
+
+	if got := atomic.LoadInt32(&calls); got != 1 {
+		t.Fatalf("fn called %d times; want 1", got)
+	}
+	if got := atomic.LoadInt32(&dups); got != n-1 {
+		t.Errorf("OnDuplicate called %d times; want %d", got, n-1)
+	}
+}
+
+// 执行请求的调用者出错时，等待的调用者重新发起请求，不会得到同一个错误
+func TestIndependentErrors(t *testing.T) {
+	g := Group{IndependentErrors: true}
+	release := make(chan struct{})
+	var calls int32
+	fn := func() (interface{}, error) {
+		if atomic.AddInt32(&calls, 1) == 1 {
+			<-release
+			return nil, errors.New("transient")
+		}
+		return "bar", nil
+	}
+
+	leader := g.DoChan("key", fn)
+	const n = 5
+	var wg sync.WaitGroup
+	errs := make(chan error, 2*n)
+	for i := 0; i < n; i++ {
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			v, err := g.Do("key", fn)
+			if err == nil && v != "bar" {
+				err = fmt.Errorf("Do = %v; want bar", v)
+			}
+			errs <- err
+		}()
+		go func() {
+			defer wg.Done()
+			res := <-g.DoChan("key", fn)
+			if res.Err == nil && res.Val != "bar" {
+				res.Err = fmt.Errorf("DoChan = %v; want bar", res.Val)
+			}
+			errs <- res.Err
+		}()
+	}
+	for g.Stats().Calls < 2*n+1 {
+		time.Sleep(time.Millisecond)
+	}
+	close(release)
+	wg.Wait()
+	close(errs)
+
+	if res := <-leader; res.Err == nil || res.Err.Error() != "transient" {
+		t.Errorf("leader got error %v; want transient", res.Err)
+	}
+	for err := range errs {
+		if err != nil {
+			t.Errorf("waiter got error %v; want nil", err)
+		}
+	}
+	// 等待的调用者被唤醒的时间不同，重新发起的请求不一定合并，但最多每个调用者执行一次
+	if got := atomic.LoadInt32(&calls); got < 2 || got > 2*n+1 {
+		t.Errorf("fn called %d times; want between 2 and %d", got, 2*n+1)
+	}
+}
+
+// 默认情况下错误结果被所有调用者共享
+func TestSharedErrors(t *testing.T) {
+	var g Group
+	release := make(chan struct{})
+	var calls int32
+	fn := func() (interface{}, error) {
+		atomic.AddInt32(&calls, 1)
+		<-release
+		return nil, errors.New("transient")
+	}
+	leader := g.DoChan("key", fn)
+	waiter := g.DoChan("key", fn)
+	close(release)
+	<-leader
+	if res := <-waiter; res.Err == nil {
+		t.Error("waiter got nil error; want the shared error")
+	}
+	if got := atomic.LoadInt32(&calls); got != 1 {
+		t.Errorf("fn called %d times; want 1", got)
+	}
+}
+
+// 所有调用者都取消时，传给fn的context被取消
+func TestDoContextCancelAll(t *testing.T) {
+	var g Group
+	started := make(chan struct{})
+	cancelled := make(chan struct{})
+	fn := func(ctx context.Context) (interface{}, error) {
+		close(started)
+		<-ctx.Done()
+		close(cancelled)
+		return nil, ctx.Err()
+	}
+
+	const n = 3
+	var cancels []context.CancelFunc
+	errs := make(chan error, n)
+	for i := 0; i < n; i++ {
+		ctx, cancel := context.WithCancel(context.Background())
+		cancels = append(cancels, cancel)
+		go func() {
+			_, err := g.DoContext(ctx, "key", fn)
+			errs <- err
+		}()
+	}
+	<-started
+	for g.Stats().Calls < n {
+		time.Sleep(time.Millisecond)
+	}
+
+	// 还有调用者在等待时不会取消
+	for _, cancel := range cancels[:n-1] {
+		cancel()
+	}
+	for i := 0; i < n-1; i++ {
+		if err := <-errs; err != context.Canceled {
+			t.Errorf("DoContext error = %v; want context.Canceled", err)
+		}
+	}
+	select {
+	case <-cancelled:
+		t.Fatal("fn context cancelled while a caller is still waiting")
+	case <-time.After(10 * time.Millisecond):
+	}
+
+	cancels[n-1]()
+	if err := <-errs; err != context.Canceled {
+		t.Errorf("DoContext error = %v; want context.Canceled", err)
+	}
+	select {
+	case <-cancelled:
+	case <-time.After(time.Second):
+		t.Fatal("fn context not cancelled after all callers gave up")
+	}
+
+	// 之后的请求重新执行
+	v, err := g.DoContext(context.Background(), "key", func(context.Context) (interface{}, error) {
+		return "bar", nil
+	})
+	if v != "bar" || err != nil {
+		t.Errorf("DoContext after cancel = %v, %v; want bar, nil", v, err)
+	}
+}
+
+// Reset不影响正在执行的请求和已经在等待的调用者
+func TestReset(t *testing.T) {
+	var g Group
+	release := make(chan struct{})
+	fn := func() (interface{}, error) {
+		<-release
+		return "bar", nil
+	}
+	leader := g.DoChan("key", fn)
+	waiter := g.DoChan("key", fn)
+
+	g.Reset()
+	if got := g.Stats().InFlight; got != 0 {
+		t.Errorf("got %d in-flight keys after Reset; want 0", got)
+	}
+
+	// Reset之后的请求重新执行
+	v, err := g.Do("key", func() (interface{}, error) { return "baz", nil })
+	if v != "baz" || err != nil {
+		t.Errorf("Do after Reset = %v, %v; want baz, nil", v, err)
+	}
+
+	close(release)
+	for _, ch := range []<-chan Result{leader, waiter} {
+		if res := <-ch; res.Val != "bar" || res.Err != nil {
+			t.Errorf("got %v, %v; want bar, nil", res.Val, res.Err)
+		}
+	}
+}
+
+func TestInFlight(t *testing.T) {
+	var g Group
+	if got := g.InFlight(); got != 0 {
+		t.Fatalf("InFlight = %d; want 0", got)
+	}
+
+	var releases []chan struct{}
+	var results []<-chan Result
+	for i := 0; i < 3; i++ {
+		release := make(chan struct{})
+		releases = append(releases, release)
+		results = append(results, g.DoChan(fmt.Sprint("key", i), func() (interface{}, error) {
+			<-release
+			return nil, nil
+		}))
+		// 重复的key不会增加数量
+		g.DoChan(fmt.Sprint("key", i), func() (interface{}, error) { return nil, nil })
+		if got := g.InFlight(); got != i+1 {
+			t.Errorf("InFlight = %d after starting %d keys; want %d", got, i+1, i+1)
+		}
+	}
+	for i, release := range releases {
+		close(release)
+		<-results[i]
+		if got, want := g.InFlight(), len(releases)-i-1; got != want {
+			t.Errorf("InFlight = %d after finishing %d keys; want %d", got, i+1, want)
+		}
+	}
+}
+
+// fn对同一个key调用Do时返回ErrReentrant，而不是死锁
+func TestDetectReentrancy(t *testing.T) {
+	g := Group{DetectReentrancy: true}
+	var inner error
+	v, err := g.Do("key", func() (interface{}, error) {
+		_, inner = g.Do("key", func() (interface{}, error) {
+			return "inner", nil
+		})
+		// 其他key不受影响
+		return g.Do("other", func() (interface{}, error) {
+			return "bar", nil
+		})
+	})
+	if !errors.Is(inner, ErrReentrant) {
+		t.Errorf("reentrant Do error = %v; want ErrReentrant", inner)
+	}
+	if v != "bar" || err != nil {
+		t.Errorf("Do = %v, %v; want bar, nil", v, err)
+	}
+
+	// DoChan在其他协程中执行fn，同样可以检测
+	res := <-g.DoChan("key", func() (interface{}, error) {
+		return g.Do("key", func() (interface{}, error) { return "inner", nil })
+	})
+	if !errors.Is(res.Err, ErrReentrant) {
+		t.Errorf("reentrant Do in DoChan error = %v; want ErrReentrant", res.Err)
+	}
+}
+
+// 大量key完成之后重建哈希表，正在执行的请求不受影响
+func TestShrink(t *testing.T) {
+	var g Group
+	release := make(chan struct{})
+	fn := func() (interface{}, error) {
+		<-release
+		return "bar", nil
+	}
+	inflight := g.DoChan("inflight", fn)
+
+	done := make(chan struct{})
+	const n = 4 * shrinkMinPeak
+	var chans []<-chan Result
+	for i := 0; i < n; i++ {
+		chans = append(chans, g.DoChan(fmt.Sprint("key", i), func() (interface{}, error) {
+			<-done
+			return nil, nil
+		}))
+	}
+	if got := g.Len(); got != n+1 {
+		t.Fatalf("Len = %d; want %d", got, n+1)
+	}
+	close(done)
+	for _, ch := range chans {
+		<-ch
+	}
+
+	g.mu.Lock()
+	peak := g.peak
+	g.mu.Unlock()
+	if peak > n/shrinkRatio {
+		t.Errorf("peak = %d after churn; want the map rebuilt below %d", peak, n/shrinkRatio)
+	}
+	if got := g.Len(); got != 1 {
+		t.Fatalf("Len = %d; want 1", got)
+	}
+
+	// 重建之后正在执行的请求仍然可以合并
+	waiter := g.DoChan("inflight", fn)
+	close(release)
+	if res := <-inflight; res.Val != "bar" {
+		t.Errorf("in-flight call got %v; want bar", res.Val)
+	}
+	if res := <-waiter; res.Val != "bar" || !res.Shared {
+		t.Errorf("waiter got %v, shared=%v; want bar, true", res.Val, res.Shared)
+	}
+}
+
+// 等价的key合并为一次执行
+func TestKeyFunc(t *testing.T) {
+	g := Group{KeyFunc: func(key string) string {
+		return strings.ToLower(strings.TrimSuffix(key, "/"))
+	}}
+	release := make(chan struct{})
+	var calls int32
+	fn := func() (interface{}, error) {
+		atomic.AddInt32(&calls, 1)
+		<-release
+		return "bar", nil
+	}
+	leader := g.DoChan("A/", fn)
+	waiter := g.DoChan("a", fn)
+	if got := g.InFlight(); got != 1 {
+		t.Errorf("InFlight = %d; want 1", got)
+	}
+	close(release)
+	<-leader
+	if res := <-waiter; res.Val != "bar" || !res.Shared {
+		t.Errorf("waiter got %v, shared=%v; want bar, true", res.Val, res.Shared)
+	}
+	if got := atomic.LoadInt32(&calls); got != 1 {
+		t.Errorf("fn called %d times; want 1", got)
+	}
+
+	// DoMulti中等价的key只执行一次，返回值包含每一种写法
+	res, err := g.DoMulti([]string{"B", "b/", "c"}, func(missing []string) (map[string]interface{}, error) {
+		if got, want := fmt.Sprint(missing), "[B c]"; got != want {
+			t.Errorf("missing = %s; want %s", got, want)
+		}
+		return map[string]interface{}{"B": 1, "c": 2}, nil
+	})
+	if got, want := fmt.Sprint(res), "map[B:1 b/:1 c:2]"; err != nil || got != want {
+		t.Errorf("DoMulti = %s, %v; want %s, nil", got, err, want)
+	}
+}
+
+// 关闭期间正在执行的请求仍然完成，之后的请求返回ErrClosed
+func TestClose(t *testing.T) {
+	var g Group
+	release := make(chan struct{})
+	fn := func() (interface{}, error) {
+		<-release
+		return "bar", nil
+	}
+	leader := g.DoChan("key", fn)
+	waiter := g.DoChan("key", fn)
+
+	g.Close()
+	var calls int32
+	v, err := g.Do("key", func() (interface{}, error) {
+		atomic.AddInt32(&calls, 1)
+		return nil, nil
+	})
+	if v != nil || err != ErrClosed {
+		t.Errorf("Do after Close = %v, %v; want nil, ErrClosed", v, err)
+	}
+	if res := <-g.DoChan("other", fn); res.Err != ErrClosed {
+		t.Errorf("DoChan after Close error = %v; want ErrClosed", res.Err)
+	}
+	if atomic.LoadInt32(&calls) != 0 {
+		t.Error("fn called after Close")
+	}
+
+	drained := make(chan struct{})
+	go func() {
+		g.Wait()
+		close(drained)
+	}()
+	select {
+	case <-drained:
+		t.Fatal("Wait returned before the in-flight call finished")
+	case <-time.After(10 * time.Millisecond):
+	}
+
+	close(release)
+	select {
+	case <-drained:
+	case <-time.After(time.Second):
+		t.Fatal("Wait did not return after the in-flight call finished")
+	}
+	for _, ch := range []<-chan Result{leader, waiter} {
+		if res := <-ch; res.Val != "bar" || res.Err != nil {
+			t.Errorf("got %v, %v; want bar, nil", res.Val, res.Err)
+		}
+	}
+}
+
+// 每次执行fn触发一次OnComplete，等待的调用者不触发
+func TestOnComplete(t *testing.T) {
+	var mu sync.Mutex
+	completed := make(map[string]int)
+	g := Group{OnComplete: func(key string, d time.Duration, err error) {
+		if d < 0 {
+			t.Errorf("OnComplete duration = %v; want >= 0", d)
+		}
+		if key == "fail" && err == nil {
+			t.Error("OnComplete error = nil for a failing call")
+		}
+		mu.Lock()
+		completed[key]++
+		mu.Unlock()
+	}}
+	release := make(chan struct{})
+	fn := func() (interface{}, error) {
+		<-release
+		return "bar", nil
+	}
+	var chans []<-chan Result
+	for i := 0; i < 5; i++ {
+		chans = append(chans, g.DoChan("key", fn))
+	}
+	close(release)
+	for _, ch := range chans {
+		<-ch
+	}
+	g.Do("fail", func() (interface{}, error) { return nil, errors.New("fail") })
+	g.DoMulti([]string{"a", "b"}, func(missing []string) (map[string]interface{}, error) {
+		return nil, nil
+	})
+
+	mu.Lock()
+	defer mu.Unlock()
+	if got, want := fmt.Sprint(completed), "map[a:1 b:1 fail:1 key:1]"; got != want {
+		t.Errorf("OnComplete calls = %s; want %s", got, want)
+	}
+}
+
+func TestDoWithFallback(t *testing.T) {
+	var g Group
+	fallback := func() interface{} { return "fallback" }
+	if v := g.DoWithFallback("key", time.Second, func() (interface{}, error) {
+		return "bar", nil
+	}, fallback); v != "bar" {
+		t.Errorf("DoWithFallback = %v; want bar", v)
+	}
+	if v := g.DoWithFallback("fail", time.Second, func() (interface{}, error) {
+		return nil, errors.New("fail")
+	}, fallback); v != "fallback" {
+		t.Errorf("DoWithFallback with error = %v; want fallback", v)
+	}
+
+	// 超时返回fallback，请求继续执行，其他调用者仍然得到结果
+	release := make(chan struct{})
+	slow := func() (interface{}, error) {
+		<-release
+		return "slow", nil
+	}
+	ch := g.DoChan("slow", slow)
+	if v := g.DoWithFallback("slow", 10*time.Millisecond, slow, fallback); v != "fallback" {
+		t.Errorf("DoWithFallback after timeout = %v; want fallback", v)
+	}
+	close(release)
+	if res := <-ch; res.Val != "slow" {
+		t.Errorf("DoChan = %v; want slow", res.Val)
+	}
+}
+
+// 出错之后重新发起请求的调用者不会再次等待已经完成的请求
+func TestIndependentErrorsStats(t *testing.T) {
+	var dups int64
+	g := Group{IndependentErrors: true, OnDuplicate: func(string) { atomic.AddInt64(&dups, 1) }}
+	release := make(chan struct{})
+	var calls int32
+	fn := func() (interface{}, error) {
+		if atomic.AddInt32(&calls, 1) == 1 {
+			<-release
+			return nil, errors.New("transient")
+		}
+		return "bar", nil
+	}
+
+	// 1个等待的调用者：等待1次，出错之后自己执行
+	leader := g.DoChan("key", fn)
+	waiter := make(chan interface{})
+	go func() {
+		v, _ := g.Do("key", fn)
+		waiter <- v
+	}()
+	for g.Stats().Dedups < 1 {
+		time.Sleep(time.Millisecond)
+	}
+	close(release)
+	<-leader
+	if v := <-waiter; v != "bar" {
+		t.Errorf("waiter got %v; want bar", v)
+	}
+	stats := g.Stats()
+	if stats.Calls != 2 || stats.Executions != 2 || stats.Dedups != 1 {
+		t.Errorf("Stats = %+v; want 2 calls, 2 executions, 1 dedup", stats)
+	}
+	if got := atomic.LoadInt64(&dups); got != 1 {
+		t.Errorf("OnDuplicate called %d times; want 1", got)
+	}
+
+	// 多个等待的调用者：每个调用者最多等待2次，OnDuplicate和Dedups一致
+	g.ResetStats()
+	atomic.StoreInt64(&dups, 0)
+	atomic.StoreInt32(&calls, 0)
+	release = make(chan struct{})
+	leader = g.DoChan("key", fn)
+	const n = 16
+	var wg sync.WaitGroup
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			g.Do("key", fn)
+		}()
+	}
+	for g.Stats().Dedups < n {
+		time.Sleep(time.Millisecond)
+	}
+	close(release)
+	<-leader
+	wg.Wait()
+	stats = g.Stats()
+	if got := atomic.LoadInt64(&dups); got != stats.Dedups {
+		t.Errorf("OnDuplicate called %d times; want %d", got, stats.Dedups)
+	}
+	if stats.Dedups > 2*n || stats.Calls != n+1 {
+		t.Errorf("Stats = %+v; want %d calls and at most %d dedups", stats, n+1, 2*n)
+	}
+}
+
+// IndependentErrors不会为已经放弃等待的DoContext调用者重新发起请求
+// 重新发起的请求在剩下的调用者都放弃之后取消
+func TestIndependentErrorsDoContextCancel(t *testing.T) {
+	g := Group{IndependentErrors: true}
+	release := make(chan struct{})
+	retryCancelled := make(chan struct{})
+	var calls int32
+	fn := func(ctx context.Context) (interface{}, error) {
+		if atomic.AddInt32(&calls, 1) == 1 {
+			<-release
+			return nil, errors.New("transient")
+		}
+		<-ctx.Done()
+		close(retryCancelled)
+		return nil, ctx.Err()
+	}
+
+	leader := make(chan error, 1)
+	go func() {
+		_, err := g.DoContext(context.Background(), "key", fn)
+		leader <- err
+	}()
+	for g.Stats().Calls < 1 {
+		time.Sleep(time.Millisecond)
+	}
+	join := func(ctx context.Context) chan error {
+		errs := make(chan error, 1)
+		dedups := g.Stats().Dedups
+		go func() {
+			_, err := g.DoContext(ctx, "key", fn)
+			errs <- err
+		}()
+		for g.Stats().Dedups == dedups {
+			time.Sleep(time.Millisecond)
+		}
+		return errs
+	}
+
+	// 放弃等待的调用者
+	ctxA, cancelA := context.WithCancel(context.Background())
+	errsA := join(ctxA)
+	cancelA()
+	if err := <-errsA; err != context.Canceled {
+		t.Fatalf("cancelled DoContext error = %v; want context.Canceled", err)
+	}
+	// 还在等待的调用者
+	ctxB, cancelB := context.WithCancel(context.Background())
+	errsB := join(ctxB)
+
+	close(release)
+	if err := <-leader; err == nil {
+		t.Fatal("leader DoContext error = nil; want transient error")
+	}
+	if got := g.Stats().Executions; got != 2 {
+		t.Fatalf("got %d executions; want 2", got)
+	}
+
+	cancelB()
+	if err := <-errsB; err != context.Canceled {
+		t.Fatalf("DoContext error = %v; want context.Canceled", err)
+	}
+	select {
+	case <-retryCancelled:
+	case <-time.After(time.Second):
+		t.Fatal("retried fn context not cancelled after all callers gave up")
+	}
+}
+
+// 请求完成时先删除哈希表记录，再唤醒等待的调用者
+func TestFinishRemovesBeforeWake(t *testing.T) {
+	var g Group
+	release := make(chan struct{})
+	ch := g.DoChan("key", func() (interface{}, error) {
+		<-release
+		return nil, errors.New("fail")
+	})
+	g.mu.Lock()
+	c := g.m["key"]
+	woken := make(chan struct{})
+	go func() {
+		c.wg.Wait()
+		close(woken)
+	}()
+	close(release)
+
+	// 持有锁时无法删除记录，等待的调用者不能被唤醒
+	select {
+	case <-woken:
+		t.Error("waiter woken before the call was removed from the map")
+	case <-time.After(20 * time.Millisecond):
+	}
+	g.mu.Unlock()
+	<-woken
+	<-ch
+	if got := g.InFlight(); got != 0 {
+		t.Errorf("InFlight = %d; want 0", got)
+	}
+}
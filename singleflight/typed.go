@@ -0,0 +1,96 @@
+/*
+Copyright 2012 Google Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package singleflight
+
+import (
+	"runtime/debug"
+	"sync"
+)
+
+// TypedGroup是使用泛型的Group，键和结果不需要转换为interface{}
+// 和Group一样，fn发生panic时所有调用者重新panic，调用runtime.Goexit时等待的调用者得到错误
+type TypedGroup[K comparable, V any] struct {
+	mu sync.Mutex
+	m  map[K]*typedCall[V]
+}
+
+// 执行中或者执行完成的结果
+type typedCall[V any] struct {
+	wg  sync.WaitGroup
+	val V
+	err error
+}
+
+// 保证对同一个key的请求不会出现并发重复操作
+// 如果存在重复请求，等待上一个操作完成返回相同响应
+func (g *TypedGroup[K, V]) Do(key K, fn func() (V, error)) (V, error) {
+	g.mu.Lock()
+	if g.m == nil {
+		g.m = make(map[K]*typedCall[V])
+	}
+	if c, ok := g.m[key]; ok {
+		g.mu.Unlock()
+		c.wg.Wait()
+		if pe, ok := c.err.(*PanicError); ok {
+			panic(pe)
+		}
+		return c.val, c.err
+	}
+	c := new(typedCall[V])
+	c.wg.Add(1)
+	g.m[key] = c
+	g.mu.Unlock()
+
+	g.doCall(c, key, fn)
+	if pe, ok := c.err.(*PanicError); ok {
+		panic(pe)
+	}
+	return c.val, c.err
+}
+
+// 执行请求操作，完成之后删除对应的哈希表记录
+func (g *TypedGroup[K, V]) doCall(c *typedCall[V], key K, fn func() (V, error)) {
+	normalReturn := false
+	defer func() {
+		c.wg.Done()
+		g.mu.Lock()
+		if g.m[key] == c {
+			delete(g.m, key)
+		}
+		g.mu.Unlock()
+	}()
+	defer func() {
+		if normalReturn {
+			return
+		}
+		if r := recover(); r != nil {
+			c.err = &PanicError{Value: r, Stack: debug.Stack()}
+		} else {
+			c.err = errGoexit
+		}
+	}()
+
+	c.val, c.err = fn()
+	normalReturn = true
+}
+
+// 忘记key对应的请求，之后对key的请求会重新执行
+func (g *TypedGroup[K, V]) Forget(key K) {
+	g.mu.Lock()
+	delete(g.m, key)
+	g.mu.Unlock()
+}
@@ -0,0 +1,74 @@
+/*
+Copyright 2012 Google Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package singleflight
+
+import (
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestTypedGroupDupSuppress(t *testing.T) {
+	var g TypedGroup[int, string]
+	release := make(chan struct{})
+	var calls int32
+	fn := func() (string, error) {
+		atomic.AddInt32(&calls, 1)
+		<-release
+		return "bar", nil
+	}
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if v, err := g.Do(1, fn); v != "bar" || err != nil {
+				t.Errorf("Do = %q, %v; want bar, nil", v, err)
+			}
+		}()
+	}
+	time.Sleep(100 * time.Millisecond)
+	close(release)
+	wg.Wait()
+
+	if got := atomic.LoadInt32(&calls); got != 1 {
+		t.Errorf("number of calls = %d; want 1", got)
+	}
+}
+
+// 对比泛型和interface{}版本的内存分配
+func BenchmarkTypedGroupDo(b *testing.B) {
+	var g TypedGroup[string, int]
+	fn := func() (int, error) { return 1234, nil }
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		v, _ := g.Do("key", fn)
+		_ = v + 1
+	}
+}
+
+func BenchmarkGroupDo(b *testing.B) {
+	var g Group
+	fn := func() (interface{}, error) { return 1234, nil }
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		v, _ := g.Do("key", fn)
+		_ = v.(int) + 1
+	}
+}